@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Client secret file as downloaded from the google cloud console,
+// desktop clients use the installed key and web clients the web key
+type clientSecretFile struct {
+	Installed *clientSecret `json:"installed"`
+	Web       *clientSecret `json:"web"`
+}
+
+type clientSecret struct {
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// Reads the client id and secret from a client secret file
+func ReadClientSecretFile(path string) (string, string, bool, error) {
+	content, exists, err := ReadFile(path)
+	if err != nil || !exists {
+		return "", "", exists, err
+	}
+
+	var file clientSecretFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return "", "", true, fmt.Errorf("Failed to parse client secret file %s: %s", path, err)
+	}
+
+	secret := file.Installed
+	if secret == nil {
+		secret = file.Web
+	}
+
+	if secret == nil || secret.ClientId == "" {
+		return "", "", true, fmt.Errorf("Client secret file %s has no client_id", path)
+	}
+
+	return secret.ClientId, secret.ClientSecret, true, nil
+}
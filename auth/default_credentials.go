@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const GoogleTokenUrl = "https://oauth2.googleapis.com/token"
+const CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Credentials found by FindDefaultCredentials
+type DefaultCredentials struct {
+	TokenSource oauth2.TokenSource
+
+	// Where the credentials were found
+	Source string
+}
+
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// authorized_user
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Finds application default credentials the way the google client libraries
+// do: the file in GOOGLE_APPLICATION_CREDENTIALS, the file written by
+// 'gcloud auth application-default login' and last the metadata server.
+// A subject is impersonated with domain-wide delegation
+func FindDefaultCredentials(scope, subject string) (*DefaultCredentials, error) {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		tokenSource, err := NewCredentialsFileTokenSource(path, scope, subject)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to use GOOGLE_APPLICATION_CREDENTIALS: %s", err)
+		}
+		return &DefaultCredentials{tokenSource, path}, nil
+	}
+
+	if path := wellKnownCredentialsFile(); fileExists(path) {
+		tokenSource, err := NewCredentialsFileTokenSource(path, scope, subject)
+		if err != nil {
+			return nil, err
+		}
+		return &DefaultCredentials{tokenSource, path}, nil
+	}
+
+	email, err := metadataServiceAccountEmail()
+	if err != nil {
+		return nil, fmt.Errorf("No application default credentials found. Set GOOGLE_APPLICATION_CREDENTIALS, run 'gcloud auth application-default login' or run on a host with a metadata server (%s)", err)
+	}
+
+	var tokenSource oauth2.TokenSource = &metadataSource{scope: scope}
+	if subject != "" {
+		tokenSource = newDelegatedSource(&metadataSource{scope: CloudPlatformScope}, IamCredentialsUrl, email, subject, scope)
+	}
+
+	source := fmt.Sprintf("metadata server %s (%s)", metadataHost(), email)
+	return &DefaultCredentials{oauth2.ReuseTokenSource(nil, tokenSource), source}, nil
+}
+
+// Returns a token source for a service account key, external account
+// or authorized user credentials file
+func NewCredentialsFileTokenSource(path, scope, subject string) (oauth2.TokenSource, error) {
+	content, exists, err := ReadFile(path)
+	if !exists {
+		return nil, fmt.Errorf("Credentials file %q not found", path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return credentialsTokenSource(content, scope, subject)
+}
+
+func credentialsTokenSource(content []byte, scope, subject string) (oauth2.TokenSource, error) {
+	var file credentialsFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("Failed to parse credentials: %s", err)
+	}
+
+	switch file.Type {
+	case "service_account":
+		conf, err := google.JWTConfigFromJSON(content, scope)
+		if err != nil {
+			return nil, err
+		}
+		conf.Subject = subject
+		return conf.TokenSource(oauth2.NoContext), nil
+	case "external_account":
+		return newExternalAccountSource(content, scope, subject)
+	case "authorized_user":
+		if subject != "" {
+			return nil, fmt.Errorf("Impersonating %s requires service account credentials, these are user credentials", subject)
+		}
+		return NewRefreshTokenSource(file.ClientId, file.ClientSecret, file.RefreshToken), nil
+	default:
+		return nil, fmt.Errorf("Unsupported credentials type '%s'", file.Type)
+	}
+}
+
+// The file written by 'gcloud auth application-default login'
+func wellKnownCredentialsFile() string {
+	const name = "application_default_credentials.json"
+
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", name)
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config", "gcloud", name)
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const DeviceCodeUrl = "https://oauth2.googleapis.com/device/code"
+const DeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// Poll interval used when the server does not give one,
+// and the increase requested by a slow_down response
+const defaultDeviceInterval = 5 * time.Second
+const slowDownIncrease = 5 * time.Second
+
+// Waits between polls, replaced in tests
+var sleep = time.Sleep
+
+type deviceCodeResponse struct {
+	DeviceCode       string `json:"device_code"`
+	UserCode         string `json:"user_code"`
+	VerificationUrl  string `json:"verification_url"`
+	VerificationUri  string `json:"verification_uri"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Interval         int64  `json:"interval"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Authorizes with the device authorization grant, for hosts without a
+// browser. The user enters the shown code on another device while the
+// token endpoint is polled until the login is approved, denied or expires
+func DeviceLogin(conf *oauth2.Config, deviceUrl string, prompt LoginPrompt) (*oauth2.Token, error) {
+	params := url.Values{
+		"client_id": {conf.ClientID},
+		"scope":     {strings.Join(conf.Scopes, " ")},
+	}
+
+	res, err := http.PostForm(deviceUrl, params)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request device code: %s", err)
+	}
+	defer res.Body.Close()
+
+	var code deviceCodeResponse
+	if err := json.NewDecoder(res.Body).Decode(&code); err != nil {
+		return nil, fmt.Errorf("Failed to decode device code response (status %d): %s", res.StatusCode, err)
+	}
+
+	if code.Error != "" {
+		return nil, fmt.Errorf("Failed to request device code: %s", tokenError{code.Error, code.ErrorDescription})
+	}
+
+	if code.DeviceCode == "" || code.UserCode == "" {
+		return nil, fmt.Errorf("Failed to request device code, status %d", res.StatusCode)
+	}
+
+	// Google uses verification_url while the standard says verification_uri
+	verificationUrl := code.VerificationUrl
+	if verificationUrl == "" {
+		verificationUrl = code.VerificationUri
+	}
+	prompt.ShowDeviceCode(verificationUrl, code.UserCode)
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDeviceInterval
+	}
+
+	expires := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	if code.ExpiresIn <= 0 {
+		expires = time.Now().Add(LoginTimeout)
+	}
+
+	for {
+		sleep(interval)
+
+		if time.Now().After(expires) {
+			return nil, fmt.Errorf("Device code expired before the login was approved")
+		}
+
+		token, err := requestToken(conf, url.Values{
+			"grant_type":  {DeviceGrantType},
+			"device_code": {code.DeviceCode},
+		})
+		if err == nil {
+			return token, nil
+		}
+
+		tokenErr, ok := err.(tokenError)
+		if !ok {
+			return nil, err
+		}
+
+		switch tokenErr.code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += slowDownIncrease
+		case "access_denied":
+			return nil, fmt.Errorf("Login was denied")
+		case "expired_token":
+			return nil, fmt.Errorf("Device code expired before the login was approved")
+		default:
+			return nil, err
+		}
+	}
+}
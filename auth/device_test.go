@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Serves a device code and answers the token polls with the given errors,
+// followed by a token. The intervals waited between polls are returned
+func deviceLogin(t *testing.T, pollErrors ...string) ([]time.Duration, *oauth2.Token, error) {
+	var polls int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/device":
+			if r.PostForm.Get("client_id") != "id" || r.PostForm.Get("scope") != "a b" {
+				t.Errorf("unexpected device code request: %v", r.PostForm)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      "dc",
+				"user_code":        "UC",
+				"verification_url": "https://verify.invalid",
+				"expires_in":       1800,
+				"interval":         2,
+			})
+		case "/token":
+			if r.PostForm.Get("grant_type") != DeviceGrantType || r.PostForm.Get("device_code") != "dc" {
+				t.Errorf("unexpected token request: %v", r.PostForm)
+			}
+			if polls < len(pollErrors) {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": pollErrors[polls]})
+			} else {
+				json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "at", "expires_in": 3600})
+			}
+			polls++
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = time.Sleep }()
+
+	conf := &oauth2.Config{
+		ClientID: "id",
+		Scopes:   []string{"a", "b"},
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/token"},
+	}
+
+	prompt := LoginPrompt{
+		ShowDeviceCode: func(url, code string) {
+			if url != "https://verify.invalid" || code != "UC" {
+				t.Errorf("ShowDeviceCode(%q, %q)", url, code)
+			}
+		},
+	}
+
+	token, err := DeviceLogin(conf, server.URL+"/device", prompt)
+	return waits, token, err
+}
+
+func TestDeviceLoginSlowDown(t *testing.T) {
+	waits, token, err := deviceLogin(t, "authorization_pending", "slow_down", "authorization_pending", "slow_down")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "at" {
+		t.Errorf("token = %+v", token)
+	}
+
+	// Each slow_down increases the interval for all following polls
+	want := []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second, 7 * time.Second, 12 * time.Second}
+	if !reflect.DeepEqual(waits, want) {
+		t.Errorf("waited %v, want %v", waits, want)
+	}
+}
+
+func TestDeviceLoginErrors(t *testing.T) {
+	for _, pollError := range []string{"access_denied", "expired_token", "invalid_client"} {
+		waits, _, err := deviceLogin(t, "authorization_pending", pollError)
+		if err == nil {
+			t.Errorf("%s: expected error", pollError)
+		}
+		if len(waits) != 2 {
+			t.Errorf("%s: polled %d times, want 2", pollError, len(waits))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const DirectoryUsersUrl = "https://admin.googleapis.com/admin/directory/v1/users"
+const DirectoryUserScope = "https://www.googleapis.com/auth/admin.directory.user.readonly"
+
+type directoryUsersResponse struct {
+	Users []struct {
+		PrimaryEmail string `json:"primaryEmail"`
+	} `json:"users"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// Returns the email of every active user in the domain with the admin
+// sdk directory api. The client must act as an admin of the domain
+func ListDomainUsers(client *http.Client, domain string) ([]string, error) {
+	var emails []string
+	pageToken := ""
+
+	for {
+		params := url.Values{
+			"domain":     {domain},
+			"query":      {"isSuspended=false"},
+			"maxResults": {"500"},
+			"fields":     {"users(primaryEmail),nextPageToken"},
+		}
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+
+		res, err := client.Get(DirectoryUsersUrl + "?" + params.Encode())
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list users: %s", err)
+		}
+
+		var body directoryUsersResponse
+		if res.StatusCode != http.StatusOK {
+			var errRes apiErrorResponse
+			json.NewDecoder(res.Body).Decode(&errRes)
+			res.Body.Close()
+			if errRes.Error.Message != "" {
+				return nil, fmt.Errorf("Failed to list users of %s: %s", domain, errRes.Error.Message)
+			}
+			return nil, fmt.Errorf("Failed to list users of %s, status %d", domain, res.StatusCode)
+		}
+
+		err = json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode users: %s", err)
+		}
+
+		for _, user := range body.Users {
+			emails = append(emails, user.PrimaryEmail)
+		}
+
+		if body.NextPageToken == "" {
+			return emails, nil
+		}
+		pageToken = body.NextPageToken
+	}
+}
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+const KeySize = 32
+const Pbkdf2Iterations = 600000
+
+const (
+	kdfNone   = "none"
+	kdfPbkdf2 = "pbkdf2-sha256"
+)
+
+// Stores the token in a file encrypted with AES-256-GCM. The key is either
+// given directly, or derived from a passphrase with PBKDF2. The derived
+// key is kept so the passphrase is only asked for once
+type EncryptedFileStore struct {
+	Path string
+
+	// Key used instead of a passphrase if set
+	Key []byte
+
+	// Returns the passphrase, confirm is set when a new file is created
+	Passphrase func(confirm bool) (string, error)
+
+	derivedKey []byte
+	salt       []byte
+	iterations int
+}
+
+type encryptedFile struct {
+	Kdf        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (self *EncryptedFileStore) Load() ([]byte, bool, error) {
+	content, exists, err := ReadFile(self.Path)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+
+	var file encryptedFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, true, fmt.Errorf("Failed to parse encrypted token file %s: %s", self.Path, err)
+	}
+
+	key, err := self.fileKey(file)
+	if err != nil {
+		return nil, true, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, true, err
+	}
+
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, true, fmt.Errorf("Failed to decrypt token, wrong passphrase or key")
+	}
+
+	return plaintext, true, nil
+}
+
+func (self *EncryptedFileStore) Save(content []byte) error {
+	file := encryptedFile{Kdf: kdfNone}
+	key := self.Key
+
+	if key == nil {
+		if self.derivedKey == nil {
+			if err := self.newPassphraseKey(); err != nil {
+				return err
+			}
+		}
+		key = self.derivedKey
+		file.Kdf = kdfPbkdf2
+		file.Iterations = self.iterations
+		file.Salt = self.salt
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("Failed to generate nonce: %s", err)
+	}
+	file.Ciphertext = gcm.Seal(nil, file.Nonce, content, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFile(self.Path, data)
+}
+
+func (self *EncryptedFileStore) Delete() error {
+	return FileStore{self.Path}.Delete()
+}
+
+func (self *EncryptedFileStore) String() string {
+	return self.Path
+}
+
+// Returns the key for an existing file, deriving it from the passphrase if needed
+func (self *EncryptedFileStore) fileKey(file encryptedFile) ([]byte, error) {
+	switch file.Kdf {
+	case kdfNone:
+		if self.Key == nil {
+			return nil, fmt.Errorf("Token in %s is encrypted with a key, but no key is given", self.Path)
+		}
+		return self.Key, nil
+	case kdfPbkdf2:
+		if self.derivedKey != nil && hmac.Equal(self.salt, file.Salt) && self.iterations == file.Iterations {
+			return self.derivedKey, nil
+		}
+
+		passphrase, err := self.passphrase(false)
+		if err != nil {
+			return nil, err
+		}
+
+		self.derivedKey = pbkdf2([]byte(passphrase), file.Salt, file.Iterations, KeySize)
+		self.salt = file.Salt
+		self.iterations = file.Iterations
+		return self.derivedKey, nil
+	default:
+		return nil, fmt.Errorf("Unknown key derivation '%s' in %s", file.Kdf, self.Path)
+	}
+}
+
+func (self *EncryptedFileStore) newPassphraseKey() error {
+	passphrase, err := self.passphrase(true)
+	if err != nil {
+		return err
+	}
+
+	self.salt = make([]byte, 16)
+	if _, err := rand.Read(self.salt); err != nil {
+		return fmt.Errorf("Failed to generate salt: %s", err)
+	}
+
+	self.iterations = Pbkdf2Iterations
+	self.derivedKey = pbkdf2([]byte(passphrase), self.salt, self.iterations, KeySize)
+	return nil
+}
+
+func (self *EncryptedFileStore) passphrase(confirm bool) (string, error) {
+	if self.Passphrase == nil {
+		return "", fmt.Errorf("No passphrase or key given for %s", self.Path)
+	}
+
+	passphrase, err := self.Passphrase(confirm)
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase == "" {
+		return "", fmt.Errorf("Passphrase can not be empty")
+	}
+	return passphrase, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("Key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PBKDF2 with HMAC-SHA256 as described in RFC 8018
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	var key []byte
+	buf := make([]byte, 4)
+	u := make([]byte, hashLen)
+	t := make([]byte, hashLen)
+
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u = prf.Sum(u[:0])
+		copy(t, u)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
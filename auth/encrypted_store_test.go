@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// RFC 6070 inputs with their PBKDF2-HMAC-SHA256 outputs, and the
+// PBKDF2-HMAC-SHA256 test vectors of RFC 7914 section 11
+var pbkdf2Vectors = []struct {
+	password   string
+	salt       string
+	iterations int
+	key        string
+}{
+	{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+	{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+	{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+	{"pass\x00word", "sa\x00lt", 4096, "89b69d0516f829893c696226650a8687"},
+	{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+}
+
+func TestPbkdf2(t *testing.T) {
+	for _, v := range pbkdf2Vectors {
+		want, _ := hex.DecodeString(v.key)
+		key := pbkdf2([]byte(v.password), []byte(v.salt), v.iterations, len(want))
+		if !bytes.Equal(key, want) {
+			t.Errorf("pbkdf2(%q, %q, %d) = %x, want %s", v.password, v.salt, v.iterations, key, v.key)
+		}
+	}
+}
+
+func tempTokenPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "godrive-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "token.enc"), func() { os.RemoveAll(dir) }
+}
+
+func TestEncryptedFileStoreKey(t *testing.T) {
+	path, cleanup := tempTokenPath(t)
+	defer cleanup()
+
+	key := bytes.Repeat([]byte{7}, KeySize)
+	token := []byte(`{"access_token":"secret"}`)
+
+	if err := (&EncryptedFileStore{Path: path, Key: key}).Save(token); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if bytes.Contains(data, []byte("secret")) {
+		t.Errorf("token file contains the plaintext token: %s", data)
+	}
+
+	loaded, exists, err := (&EncryptedFileStore{Path: path, Key: key}).Load()
+	if err != nil || !exists || !bytes.Equal(loaded, token) {
+		t.Errorf("Load() = %q, %v, %v", loaded, exists, err)
+	}
+
+	wrongKey := bytes.Repeat([]byte{8}, KeySize)
+	if _, _, err := (&EncryptedFileStore{Path: path, Key: wrongKey}).Load(); err == nil {
+		t.Error("expected error loading with the wrong key")
+	}
+
+	if _, _, err := (&EncryptedFileStore{Path: path}).Load(); err == nil {
+		t.Error("expected error loading a key encrypted file without a key")
+	}
+}
+
+func TestEncryptedFileStorePassphrase(t *testing.T) {
+	path, cleanup := tempTokenPath(t)
+	defer cleanup()
+
+	token := []byte(`{"access_token":"secret"}`)
+	var prompts []bool
+
+	passphrase := func(value string) func(bool) (string, error) {
+		return func(confirm bool) (string, error) {
+			prompts = append(prompts, confirm)
+			return value, nil
+		}
+	}
+
+	store := &EncryptedFileStore{Path: path, Passphrase: passphrase("correct horse")}
+	if err := store.Save(token); err != nil {
+		t.Fatal(err)
+	}
+
+	// The derived key is kept, saving again does not ask for the passphrase
+	if err := store.Save(token); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, _, err := (&EncryptedFileStore{Path: path, Passphrase: passphrase("correct horse")}).Load()
+	if err != nil || !bytes.Equal(loaded, token) {
+		t.Errorf("Load() = %q, %v", loaded, err)
+	}
+
+	if want := []bool{true, false}; !reflect.DeepEqual(prompts, want) {
+		t.Errorf("passphrase prompts %v, want %v", prompts, want)
+	}
+
+	if _, _, err := (&EncryptedFileStore{Path: path, Passphrase: passphrase("wrong")}).Load(); err == nil {
+		t.Error("expected error loading with the wrong passphrase")
+	}
+
+	if _, _, err := (&EncryptedFileStore{Path: path, Passphrase: passphrase("")}).Load(); err == nil {
+		t.Error("expected error for an empty passphrase")
+	}
+}
+
+func TestEncryptedFileStoreMissing(t *testing.T) {
+	path, cleanup := tempTokenPath(t)
+	defer cleanup()
+
+	_, exists, err := (&EncryptedFileStore{Path: path}).Load()
+	if err != nil || exists {
+		t.Errorf("Load() of missing file = %v, %v", exists, err)
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+const AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+
+var impersonationUrlRegex = regexp.MustCompile(`^(.*)/projects/-/serviceAccounts/([^/:]+):generateAccessToken$`)
+
+// Workload identity federation config, see
+// https://cloud.google.com/iam/docs/workload-identity-federation
+type externalAccountConfig struct {
+	Audience                       string `json:"audience"`
+	SubjectTokenType               string `json:"subject_token_type"`
+	TokenUrl                       string `json:"token_url"`
+	ServiceAccountImpersonationUrl string `json:"service_account_impersonation_url"`
+	CredentialSource               struct {
+		File    string            `json:"file"`
+		Url     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Format  struct {
+			Type                  string `json:"type"`
+			SubjectTokenFieldName string `json:"subject_token_field_name"`
+		} `json:"format"`
+	} `json:"credential_source"`
+}
+
+// Exchanges a token from another identity provider for a google token with
+// the security token service, and impersonates a service account if set
+type externalAccountSource struct {
+	config externalAccountConfig
+	scope  string
+}
+
+func newExternalAccountSource(content []byte, scope, subject string) (oauth2.TokenSource, error) {
+	var config externalAccountConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("Failed to parse external account config: %s", err)
+	}
+
+	if config.TokenUrl == "" || config.Audience == "" || config.SubjectTokenType == "" {
+		return nil, fmt.Errorf("External account config requires token_url, audience and subject_token_type")
+	}
+
+	source := config.CredentialSource
+	if source.File == "" && source.Url == "" {
+		return nil, fmt.Errorf("Only file and url credential sources are supported")
+	}
+
+	if subject == "" {
+		return oauth2.ReuseTokenSource(nil, &externalAccountSource{config, scope}), nil
+	}
+
+	// Delegation signs as the impersonated service account
+	match := impersonationUrlRegex.FindStringSubmatch(config.ServiceAccountImpersonationUrl)
+	if match == nil {
+		return nil, fmt.Errorf("Impersonating %s requires service_account_impersonation_url in the external account config", subject)
+	}
+
+	base := oauth2.ReuseTokenSource(nil, &externalAccountSource{config, CloudPlatformScope})
+	return oauth2.ReuseTokenSource(nil, newDelegatedSource(base, match[1], match[2], subject, scope)), nil
+}
+
+func (self *externalAccountSource) Token() (*oauth2.Token, error) {
+	subjectToken, err := self.subjectToken()
+	if err != nil {
+		return nil, err
+	}
+
+	impersonate := self.config.ServiceAccountImpersonationUrl != ""
+
+	// The federated token only needs to be able to impersonate
+	scope := self.scope
+	if impersonate {
+		scope = CloudPlatformScope
+	}
+
+	token, err := postTokenRequest(self.config.TokenUrl, url.Values{
+		"grant_type":           {TokenExchangeGrantType},
+		"audience":             {self.config.Audience},
+		"scope":                {scope},
+		"requested_token_type": {AccessTokenType},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {self.config.SubjectTokenType},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to exchange token with %s: %s", self.config.TokenUrl, err)
+	}
+
+	if !impersonate {
+		return token, nil
+	}
+
+	return generateAccessToken(self.config.ServiceAccountImpersonationUrl, token.AccessToken, self.scope)
+}
+
+// Reads the token issued by the other identity provider
+func (self *externalAccountSource) subjectToken() (string, error) {
+	source := self.config.CredentialSource
+
+	var content []byte
+	var err error
+
+	if source.File != "" {
+		content, err = ioutil.ReadFile(source.File)
+		if err != nil {
+			return "", fmt.Errorf("Failed to read subject token: %s", err)
+		}
+	} else {
+		content, err = getSubjectToken(source.Url, source.Headers)
+		if err != nil {
+			return "", fmt.Errorf("Failed to get subject token from %s: %s", source.Url, err)
+		}
+	}
+
+	if source.Format.Type != "json" {
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return "", fmt.Errorf("Failed to parse subject token: %s", err)
+	}
+
+	token, ok := fields[source.Format.SubjectTokenFieldName].(string)
+	if !ok || token == "" {
+		return "", fmt.Errorf("Subject token has no field '%s'", source.Format.SubjectTokenFieldName)
+	}
+	return token, nil
+}
+
+func getSubjectToken(tokenUrl string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest("GET", tokenUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", res.StatusCode)
+	}
+	return ioutil.ReadAll(res.Body)
+}
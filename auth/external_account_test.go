@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Fake identity provider, security token service and iam credentials api
+func fakeExternalAccountServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/subject":
+			if r.Header.Get("Metadata") != "True" {
+				t.Errorf("subject token request without configured header")
+			}
+			w.Write([]byte(`{"access_token":"url-subject"}`))
+
+		case "/sts":
+			r.ParseForm()
+			form := r.PostForm
+			if form.Get("grant_type") != TokenExchangeGrantType || form.Get("audience") != "aud" || form.Get("subject_token_type") != "type" {
+				t.Errorf("unexpected token exchange: %v", form)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": "federated:" + form.Get("subject_token") + ":" + form.Get("scope"),
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+			})
+
+		case "/v1/projects/-/serviceAccounts/sa@p.iam.gserviceaccount.com:generateAccessToken":
+			var body struct {
+				Scope []string `json:"scope"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(map[string]string{
+				"accessToken": fmt.Sprintf("impersonated:%s:%v", r.Header.Get("Authorization"), body.Scope),
+				"expireTime":  "2030-01-02T15:04:05Z",
+			})
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestExternalAccountFileSource(t *testing.T) {
+	server := fakeExternalAccountServer(t)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "godrive-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte("file-subject\n"), 0600)
+
+	config := fmt.Sprintf(`{
+		"type": "external_account",
+		"audience": "aud",
+		"subject_token_type": "type",
+		"token_url": "%s/sts",
+		"credential_source": {"file": %q}
+	}`, server.URL, tokenFile)
+
+	source, err := newExternalAccountSource([]byte(config), "drive", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := source.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "federated:file-subject:drive" {
+		t.Errorf("access token = %q", token.AccessToken)
+	}
+}
+
+func TestExternalAccountUrlSourceImpersonation(t *testing.T) {
+	server := fakeExternalAccountServer(t)
+	defer server.Close()
+
+	config := fmt.Sprintf(`{
+		"type": "external_account",
+		"audience": "aud",
+		"subject_token_type": "type",
+		"token_url": "%[1]s/sts",
+		"service_account_impersonation_url": "%[1]s/v1/projects/-/serviceAccounts/sa@p.iam.gserviceaccount.com:generateAccessToken",
+		"credential_source": {
+			"url": "%[1]s/subject",
+			"headers": {"Metadata": "True"},
+			"format": {"type": "json", "subject_token_field_name": "access_token"}
+		}
+	}`, server.URL)
+
+	source, err := newExternalAccountSource([]byte(config), "drive", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := source.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The federated token only gets the cloud platform scope, the requested
+	// scope is given to the impersonated service account
+	want := fmt.Sprintf("impersonated:Bearer federated:url-subject:%s:[drive]", CloudPlatformScope)
+	if token.AccessToken != want {
+		t.Errorf("access token = %q, want %q", token.AccessToken, want)
+	}
+	if token.Expiry.Year() != 2030 {
+		t.Errorf("expiry = %s", token.Expiry)
+	}
+}
+
+func TestExternalAccountInvalidConfig(t *testing.T) {
+	configs := []string{
+		`not json`,
+		`{"audience": "aud", "subject_token_type": "type", "credential_source": {"file": "f"}}`,
+		`{"audience": "aud", "subject_token_type": "type", "token_url": "u", "credential_source": {"environment_id": "aws1"}}`,
+	}
+
+	for _, config := range configs {
+		if _, err := newExternalAccountSource([]byte(config), "drive", ""); err == nil {
+			t.Errorf("expected error for %s", config)
+		}
+	}
+
+	// Delegation requires impersonation
+	config := `{"audience": "aud", "subject_token_type": "type", "token_url": "u", "credential_source": {"file": "f"}}`
+	if _, err := newExternalAccountSource([]byte(config), "drive", "user@example.com"); err == nil {
+		t.Error("expected error for subject without service_account_impersonation_url")
+	}
+}
@@ -2,35 +2,86 @@ package auth
 
 import (
 	"encoding/json"
-	"golang.org/x/oauth2"
 	"io/ioutil"
-	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
 )
 
-func FileSource(path string, token *oauth2.Token, conf *oauth2.Config) oauth2.TokenSource {
-	return &fileSource{
-		tokenPath:   path,
+func StoreSource(store TokenStore, token *oauth2.Token, scopes []string, conf *oauth2.Config) oauth2.TokenSource {
+	return &storeSource{
+		store:       store,
+		clientId:    conf.ClientID,
+		scopes:      scopes,
 		tokenSource: conf.TokenSource(oauth2.NoContext, token),
+		saved:       token.AccessToken,
 	}
 }
 
-type fileSource struct {
-	tokenPath   string
+type storeSource struct {
+	store       TokenStore
+	clientId    string
+	scopes      []string
 	tokenSource oauth2.TokenSource
+	mutex       sync.Mutex
+	saved       string
 }
 
-func (self *fileSource) Token() (*oauth2.Token, error) {
+// Token file content, the id of the client that issued the
+// token is kept since only that client can refresh it. The granted
+// scopes are kept so they can be checked without asking Google
+type storedToken struct {
+	*oauth2.Token
+	ClientId string   `json:"client_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+func (self *storeSource) Token() (*oauth2.Token, error) {
 	token, err := self.tokenSource.Token()
 	if err != nil {
 		return token, err
 	}
 
-	// Save token to file
-	SaveToken(self.tokenPath, token)
+	// Save token when it has been refreshed, some stores are slow to write
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if token.AccessToken != self.saved {
+		// Refresh responses may leave out the scopes, they stay the same then
+		if scopes := tokenScopes(token); len(scopes) > 0 {
+			self.scopes = scopes
+		}
+		if err := SaveToken(self.store, token, self.clientId, self.scopes); err == nil {
+			self.saved = token.AccessToken
+		}
+	}
 
 	return token, nil
 }
 
+// Returns the scopes granted to the stored token, nil when they are
+// unknown, e.g. for tokens saved before the scopes were recorded or
+// when the token does not come from a store
+func StoredScopes(tokenSource oauth2.TokenSource) []string {
+	source, ok := tokenSource.(*storeSource)
+	if !ok {
+		return nil
+	}
+
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	return source.scopes
+}
+
+// Returns the scopes from the token response, nil if it has none
+func tokenScopes(token *oauth2.Token) []string {
+	scope, _ := token.Extra("scope").(string)
+	if scope == "" {
+		return nil
+	}
+	return strings.Fields(scope)
+}
+
 func ReadFile(path string) ([]byte, bool, error) {
 	if !fileExists(path) {
 		return nil, false, nil
@@ -43,36 +94,38 @@ func ReadFile(path string) ([]byte, bool, error) {
 	return content, true, nil
 }
 
-
-func ReadToken(path string) (*oauth2.Token, bool, error) {
-
-	content, exists, err := ReadFile(path)
-	if err != nil || exists == false {
+func ReadToken(store TokenStore) (*oauth2.Token, bool, error) {
+	stored, exists, err := readStoredToken(store)
+	if err != nil || !exists {
 		return nil, exists, err
 	}
-
-	token := &oauth2.Token{}
-	return token, exists, json.Unmarshal(content, token)
+	return stored.Token, true, nil
 }
 
-func SaveToken(path string, token *oauth2.Token) error {
-	data, err := json.MarshalIndent(token, "", "  ")
-	if err != nil {
-		return err
+// Returns the id of the client that issued the token,
+// empty for tokens saved before the client was recorded
+func ReadTokenClientId(store TokenStore) (string, error) {
+	stored, exists, err := readStoredToken(store)
+	if err != nil || !exists {
+		return "", err
 	}
+	return stored.ClientId, nil
+}
 
-	if err = mkdir(path); err != nil {
-		return err
+func readStoredToken(store TokenStore) (*storedToken, bool, error) {
+	content, exists, err := store.Load()
+	if err != nil || exists == false {
+		return nil, exists, err
 	}
 
-	// Write to temp file first
-	tmpFile := path + ".tmp"
-	err = ioutil.WriteFile(tmpFile, data, 0600)
+	stored := &storedToken{Token: &oauth2.Token{}}
+	return stored, exists, json.Unmarshal(content, stored)
+}
+
+func SaveToken(store TokenStore, token *oauth2.Token, clientId string, scopes []string) error {
+	data, err := json.MarshalIndent(storedToken{token, clientId, scopes}, "", "  ")
 	if err != nil {
-		os.Remove(tmpFile)
 		return err
 	}
-
-	// Move file to correct path
-	return os.Rename(tmpFile, path)
+	return store.Save(data)
 }
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestStoredScopes(t *testing.T) {
+	path, cleanup := tempTokenPath(t)
+	defer cleanup()
+
+	tests := []struct {
+		scopes []string
+		want   []string
+	}{
+		{[]string{ScopeReadonly}, []string{ScopeReadonly}},
+		{[]string{ScopeFile, ScopeReadonly}, []string{ScopeFile, ScopeReadonly}},
+		{nil, nil},
+	}
+
+	for _, test := range tests {
+		store := FileStore{Path: path}
+		token := &oauth2.Token{AccessToken: "at", RefreshToken: "rt", Expiry: time.Now().Add(time.Hour)}
+		if err := SaveToken(store, token, "client", test.scopes); err != nil {
+			t.Fatal(err)
+		}
+
+		source, err := NewStoredTokenSource("client", "secret", ScopeFull, store, LoginPrompt{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scopes := StoredScopes(source); !reflect.DeepEqual(scopes, test.want) {
+			t.Errorf("StoredScopes() = %v, want %v", scopes, test.want)
+		}
+	}
+
+	if scopes := StoredScopes(NewAccessTokenSource("client", "secret", "at")); scopes != nil {
+		t.Errorf("StoredScopes(access token) = %v, want nil", scopes)
+	}
+}
+
+func TestTokenScopes(t *testing.T) {
+	tests := []struct {
+		scope interface{}
+		want  []string
+	}{
+		{ScopeFull, []string{ScopeFull}},
+		{ScopeFile + " " + ScopeReadonly, []string{ScopeFile, ScopeReadonly}},
+		{"", nil},
+		{nil, nil},
+	}
+
+	for _, test := range tests {
+		token := (&oauth2.Token{}).WithExtra(map[string]interface{}{"scope": test.scope})
+		if scopes := tokenScopes(token); !reflect.DeepEqual(scopes, test.want) {
+			t.Errorf("tokenScopes(%v) = %v, want %v", test.scope, scopes, test.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const IamCredentialsUrl = "https://iamcredentials.googleapis.com/v1"
+const JwtBearerGrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// Lifetime of impersonated and delegated tokens
+const iamTokenLifetime = time.Hour
+
+type apiErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// Gets tokens for a user of the domain without a service account key. The
+// claims are signed by the IAM credentials api with the key google holds
+// for the service account, which requires the Service Account Token Creator
+// role on the service account itself
+type delegatedSource struct {
+	base           oauth2.TokenSource
+	iamUrl         string
+	tokenUrl       string
+	serviceAccount string
+	subject        string
+	scope          string
+}
+
+func newDelegatedSource(base oauth2.TokenSource, iamUrl, serviceAccount, subject, scope string) *delegatedSource {
+	return &delegatedSource{
+		base:           base,
+		iamUrl:         iamUrl,
+		tokenUrl:       GoogleTokenUrl,
+		serviceAccount: serviceAccount,
+		subject:        subject,
+		scope:          scope,
+	}
+}
+
+func (self *delegatedSource) Token() (*oauth2.Token, error) {
+	token, err := self.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   self.serviceAccount,
+		"sub":   self.subject,
+		"scope": self.scope,
+		"aud":   self.tokenUrl,
+		"iat":   now.Unix(),
+		"exp":   now.Add(iamTokenLifetime).Unix(),
+	}
+
+	assertion, err := signJwt(self.iamUrl, self.serviceAccount, token.AccessToken, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err = postTokenRequest(self.tokenUrl, url.Values{
+		"grant_type": {JwtBearerGrantType},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get token for %s: %s", self.subject, err)
+	}
+	return token, nil
+}
+
+func signJwt(iamUrl, serviceAccount, accessToken string, claims map[string]interface{}) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		SignedJwt string `json:"signedJwt"`
+	}
+
+	endpoint := fmt.Sprintf("%s/projects/-/serviceAccounts/%s:signJwt", iamUrl, serviceAccount)
+	err = postIamRequest(endpoint, accessToken, map[string]string{"payload": string(payload)}, &res)
+	if err != nil {
+		return "", fmt.Errorf("Failed to sign jwt as %s: %s", serviceAccount, err)
+	}
+	return res.SignedJwt, nil
+}
+
+// Gets an access token for a service account with the generateAccessToken endpoint
+func generateAccessToken(endpoint, accessToken, scope string) (*oauth2.Token, error) {
+	req := map[string]interface{}{
+		"scope":    []string{scope},
+		"lifetime": fmt.Sprintf("%ds", int64(iamTokenLifetime.Seconds())),
+	}
+
+	var res struct {
+		AccessToken string `json:"accessToken"`
+		ExpireTime  string `json:"expireTime"`
+	}
+
+	if err := postIamRequest(endpoint, accessToken, req, &res); err != nil {
+		return nil, fmt.Errorf("Failed to impersonate service account: %s", err)
+	}
+
+	expiry, err := time.Parse(time.RFC3339, res.ExpireTime)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse expire time '%s': %s", res.ExpireTime, err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: res.AccessToken,
+		TokenType:   "Bearer",
+		Expiry:      expiry,
+	}, nil
+}
+
+func postIamRequest(endpoint, accessToken string, body, result interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var errRes apiErrorResponse
+		if json.NewDecoder(res.Body).Decode(&errRes) == nil && errRes.Error.Message != "" {
+			return fmt.Errorf("%s (%s)", errRes.Error.Message, errRes.Error.Status)
+		}
+		return fmt.Errorf("status %d", res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
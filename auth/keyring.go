@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const KeyringService = "godrive"
+
+// Stores the token in the Secret Service keyring (gnome-keyring, kwallet)
+// with the secret-tool command from libsecret. The account attribute
+// tells apart tokens of different config dirs
+type KeyringStore struct {
+	Account string
+}
+
+func (self KeyringStore) attributes() []string {
+	return []string{"service", KeyringService, "account", self.Account}
+}
+
+func (self KeyringStore) Load() ([]byte, bool, error) {
+	stdout, stderr, err := runSecretTool(nil, append([]string{"lookup"}, self.attributes()...)...)
+
+	// Lookup exits with 1 and no output when there is no matching secret
+	if _, ok := err.(*exec.ExitError); ok && len(stdout) == 0 && len(stderr) == 0 {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, secretToolError("read token from keyring", err, stderr)
+	}
+
+	return stdout, true, nil
+}
+
+func (self KeyringStore) Save(content []byte) error {
+	label := fmt.Sprintf("godrive token (%s)", self.Account)
+	args := append([]string{"store", "--label", label}, self.attributes()...)
+
+	_, stderr, err := runSecretTool(content, args...)
+	if err != nil {
+		return secretToolError("save token to keyring", err, stderr)
+	}
+	return nil
+}
+
+func (self KeyringStore) Delete() error {
+	_, stderr, err := runSecretTool(nil, append([]string{"clear"}, self.attributes()...)...)
+	if err != nil {
+		return secretToolError("remove token from keyring", err, stderr)
+	}
+	return nil
+}
+
+func (self KeyringStore) String() string {
+	return fmt.Sprintf("keyring (account %s)", self.Account)
+}
+
+func runSecretTool(stdin []byte, args ...string) ([]byte, []byte, error) {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, nil, fmt.Errorf("secret-tool not found, install libsecret-tools or use another token store")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	err = cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+func secretToolError(action string, err error, stderr []byte) error {
+	if msg := strings.TrimSpace(string(stderr)); msg != "" {
+		return fmt.Errorf("Failed to %s: %s", action, msg)
+	}
+	return fmt.Errorf("Failed to %s: %s", action, err)
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Time to wait for the user to authorize in the browser
+const LoginTimeout = 10 * time.Minute
+
+type LoginPrompt struct {
+	// Shows the authorization url to the user
+	ShowUrl func(url string)
+
+	// Reads the url the browser was redirected to, when the browser runs
+	// on another host and can not reach the local listener. The read must
+	// stop when done is closed, which happens when the login is over.
+	// Not used if nil
+	ReadRedirect func(done <-chan struct{}) (string, bool)
+
+	// Use the device flow instead of the loopback redirect
+	Device bool
+
+	// Shows the verification url and the code to enter there, used by the device flow
+	ShowDeviceCode func(url, code string)
+}
+
+type loginResult struct {
+	code string
+	err  error
+}
+
+// Authorizes with the loopback redirect flow. A temporary listener on
+// 127.0.0.1 receives the redirect from the browser, the request is bound
+// to this login with a random state and a PKCE code verifier.
+// The redirect url can also be pasted if the listener is unreachable
+func LoopbackLogin(conf *oauth2.Config, prompt LoginPrompt) (*oauth2.Token, error) {
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start local listener: %s", err)
+	}
+	defer listener.Close()
+
+	redirectUrl := fmt.Sprintf("http://%s/", listener.Addr().String())
+
+	c := *conf
+	c.RedirectURL = redirectUrl
+
+	results := make(chan loginResult, 1)
+
+	// Stops reading the redirect when the listener got it first
+	done := make(chan struct{})
+	defer close(done)
+
+	server := &http.Server{Handler: redirectHandler(state, results)}
+	go server.Serve(listener)
+
+	authUrl := c.AuthCodeURL(state,
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("code_challenge", codeChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	prompt.ShowUrl(authUrl)
+
+	if prompt.ReadRedirect != nil {
+		go func() {
+			value, ok := prompt.ReadRedirect(done)
+			if !ok {
+				return
+			}
+			code, err := parseRedirect(value, state)
+			sendResult(results, loginResult{code, err})
+		}()
+	}
+
+	var result loginResult
+	select {
+	case result = <-results:
+	case <-time.After(LoginTimeout):
+		return nil, fmt.Errorf("Timed out waiting for authorization")
+	}
+
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	return exchangeCode(&c, result.code, url.Values{"code_verifier": {verifier}})
+}
+
+func redirectHandler(state string, results chan<- loginResult) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ignore requests for favicon and such
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		code, err := parseRedirect(r.URL.String(), state)
+
+		// Requests without a matching state are not from this login
+		if err == errStateMismatch {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			fmt.Fprintf(w, "Authorization failed: %s\n", err)
+		} else {
+			fmt.Fprintln(w, "Authorization complete, you can close this window.")
+		}
+
+		sendResult(results, loginResult{code, err})
+	})
+}
+
+// Only the first result is used, later ones are dropped
+func sendResult(results chan<- loginResult, result loginResult) {
+	select {
+	case results <- result:
+	default:
+	}
+}
+
+var errStateMismatch = fmt.Errorf("State does not match, the response is not from this login")
+
+// Returns the code from a redirect url. The url must have the state of
+// this login, a code on its own is rejected as it can not be checked
+func parseRedirect(value, state string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	if !strings.Contains(value, "?") {
+		return "", fmt.Errorf("Not a redirect address, paste the whole address the browser was redirected to")
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse redirect url: %s", err)
+	}
+
+	query := u.Query()
+	if query.Get("state") != state {
+		return "", errStateMismatch
+	}
+
+	if query.Get("error") != "" {
+		return "", fmt.Errorf("Authorization denied: %s", query.Get("error"))
+	}
+
+	if query.Get("code") == "" {
+		return "", fmt.Errorf("No code in redirect url")
+	}
+
+	return query.Get("code"), nil
+}
+
+// Exchanges an authorization code for a token. The vendored oauth2
+// package can not send the PKCE code verifier, so the request is made here
+func exchangeCode(conf *oauth2.Config, code string, params url.Values) (*oauth2.Token, error) {
+	params.Set("grant_type", "authorization_code")
+	params.Set("code", code)
+	params.Set("redirect_uri", conf.RedirectURL)
+	return requestToken(conf, params)
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Scope            string `json:"scope"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func requestToken(conf *oauth2.Config, params url.Values) (*oauth2.Token, error) {
+	params.Set("client_id", conf.ClientID)
+	if conf.ClientSecret != "" {
+		params.Set("client_secret", conf.ClientSecret)
+	}
+
+	return postTokenRequest(conf.Endpoint.TokenURL, params)
+}
+
+// Posts a token request and parses the standard token response
+func postTokenRequest(tokenUrl string, params url.Values) (*oauth2.Token, error) {
+	res, err := http.PostForm(tokenUrl, params)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request token: %s", err)
+	}
+	defer res.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("Failed to decode token response (status %d): %s", res.StatusCode, err)
+	}
+
+	if body.Error != "" {
+		return nil, tokenError{body.Error, body.ErrorDescription}
+	}
+
+	if res.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return nil, fmt.Errorf("Failed to request token, status %d", res.StatusCode)
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  body.AccessToken,
+		TokenType:    body.TokenType,
+		RefreshToken: body.RefreshToken,
+	}
+
+	if body.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+
+	// The granted scopes may differ from the requested ones
+	if body.Scope != "" {
+		token = token.WithExtra(map[string]interface{}{"scope": body.Scope})
+	}
+
+	return token, nil
+}
+
+type tokenError struct {
+	code        string
+	description string
+}
+
+func (self tokenError) Error() string {
+	if self.description == "" {
+		return self.code
+	}
+	return fmt.Sprintf("%s: %s", self.code, self.description)
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("Failed to generate random string: %s", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
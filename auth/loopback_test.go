@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestParseRedirect(t *testing.T) {
+	tests := []struct {
+		value string
+		code  string
+		fails bool
+	}{
+		{"http://127.0.0.1:1234/?state=s1&code=c1", "c1", false},
+		{"  http://127.0.0.1:1234/?code=c1&state=s1\n", "c1", false},
+		{"http://127.0.0.1:1234/?state=other&code=c1", "", true},
+		{"http://127.0.0.1:1234/?code=c1", "", true},
+		{"c1", "", true},
+		{"", "", true},
+		{"http://127.0.0.1:1234/?state=s1&error=access_denied", "", true},
+		{"http://127.0.0.1:1234/?state=s1", "", true},
+	}
+
+	for _, test := range tests {
+		code, err := parseRedirect(test.value, "s1")
+		if test.fails != (err != nil) || code != test.code {
+			t.Errorf("parseRedirect(%q) = %q, %v, want code %q, fails %v", test.value, code, err, test.code, test.fails)
+		}
+	}
+}
+
+func TestParseRedirectStateMismatch(t *testing.T) {
+	for _, value := range []string{"http://h/?state=other&code=c1", "http://h/?code=c1"} {
+		if _, err := parseRedirect(value, "s1"); err != errStateMismatch {
+			t.Errorf("parseRedirect(%q) error = %v, want errStateMismatch", value, err)
+		}
+	}
+}
+
+func TestPostTokenRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostForm.Get("code") {
+		case "good":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  "at",
+				"token_type":    "Bearer",
+				"refresh_token": "rt",
+				"expires_in":    3600,
+				"scope":         ScopeFile,
+			})
+		case "denied":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":             "invalid_grant",
+				"error_description": "Bad code",
+			})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("{}"))
+		}
+	}))
+	defer server.Close()
+
+	token, err := postTokenRequest(server.URL, url.Values{"code": {"good"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "at" || token.RefreshToken != "rt" || token.TokenType != "Bearer" {
+		t.Errorf("token = %+v", token)
+	}
+	if d := token.Expiry.Sub(time.Now()); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expiry in %s, want an hour", d)
+	}
+	if scopes := tokenScopes(token); len(scopes) != 1 || scopes[0] != ScopeFile {
+		t.Errorf("scopes = %v, want [%s]", scopes, ScopeFile)
+	}
+
+	_, err = postTokenRequest(server.URL, url.Values{"code": {"denied"}})
+	if e, ok := err.(tokenError); !ok || e.code != "invalid_grant" || e.description != "Bad code" {
+		t.Errorf("error = %#v, want invalid_grant token error", err)
+	}
+
+	if _, err := postTokenRequest(server.URL, url.Values{"code": {"other"}}); err == nil {
+		t.Error("expected error for status 500 without access token")
+	}
+}
+
+func TestLoopbackLogin(t *testing.T) {
+	var challenge string
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form := r.PostForm
+
+		if form.Get("grant_type") != "authorization_code" || form.Get("code") != "the-code" || form.Get("client_id") != "id" {
+			t.Errorf("unexpected token request: %v", form)
+		}
+		if codeChallenge(form.Get("code_verifier")) != challenge {
+			t.Errorf("code verifier does not match the challenge")
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "at", "refresh_token": "rt", "expires_in": 3600})
+	}))
+	defer tokenServer.Close()
+
+	conf := &oauth2.Config{
+		ClientID: "id",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://auth.invalid/auth", TokenURL: tokenServer.URL},
+	}
+
+	readCanceled := make(chan struct{})
+
+	prompt := LoginPrompt{
+		// Acts as the browser following the redirect
+		ShowUrl: func(authUrl string) {
+			u, err := url.Parse(authUrl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := u.Query()
+			challenge = query.Get("code_challenge")
+
+			if query.Get("code_challenge_method") != "S256" || challenge == "" {
+				t.Errorf("auth url without PKCE challenge: %s", authUrl)
+			}
+
+			redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {"the-code"}, "state": {query.Get("state")}}.Encode()
+			go func() {
+				res, err := http.Get(redirect)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				res.Body.Close()
+			}()
+		},
+		ReadRedirect: func(done <-chan struct{}) (string, bool) {
+			<-done
+			close(readCanceled)
+			return "", false
+		},
+	}
+
+	token, err := LoopbackLogin(conf, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "at" || token.RefreshToken != "rt" {
+		t.Errorf("token = %+v", token)
+	}
+
+	select {
+	case <-readCanceled:
+	case <-time.After(5 * time.Second):
+		t.Error("reading the redirect was not canceled when the browser redirect arrived")
+	}
+}
+
+func TestLoopbackLoginIgnoresForeignState(t *testing.T) {
+	handler := redirectHandler("s1", make(chan loginResult, 1))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/?code=c1&state=s2", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Host of the GCE metadata server, overridden by GCE_METADATA_HOST
+const MetadataHost = "metadata.google.internal"
+
+const metadataTimeout = 5 * time.Second
+
+var metadataClient = &http.Client{Timeout: metadataTimeout}
+
+// Gets tokens for the default service account of the instance from the metadata server
+type metadataSource struct {
+	scope string
+}
+
+func (self *metadataSource) Token() (*oauth2.Token, error) {
+	path := "instance/service-accounts/default/token?" + url.Values{"scopes": {self.scope}}.Encode()
+	content, err := getMetadata(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get token from metadata server: %s", err)
+	}
+
+	var body tokenResponse
+	if err := json.Unmarshal(content, &body); err != nil {
+		return nil, fmt.Errorf("Failed to decode metadata server token: %s", err)
+	}
+
+	if body.AccessToken == "" {
+		return nil, fmt.Errorf("Metadata server returned no access token")
+	}
+
+	token := &oauth2.Token{
+		AccessToken: body.AccessToken,
+		TokenType:   body.TokenType,
+	}
+
+	if body.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+func metadataServiceAccountEmail() (string, error) {
+	content, err := getMetadata("instance/service-accounts/default/email")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func getMetadata(path string) ([]byte, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/computeMetadata/v1/%s", metadataHost(), path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	res, err := metadataClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata server returned status %d: %s", res.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	// Anything else listening on the address does not set the flavor
+	if res.Header.Get("Metadata-Flavor") != "Google" {
+		return nil, fmt.Errorf("%s is not a metadata server", metadataHost())
+	}
+
+	return content, nil
+}
+
+func metadataHost() string {
+	if host := os.Getenv("GCE_METADATA_HOST"); host != "" {
+		return host
+	}
+	return MetadataHost
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Points the metadata host at a test server, the returned func restores it
+func fakeMetadataServer(t *testing.T, handler http.HandlerFunc) func() {
+	server := httptest.NewServer(handler)
+	old, set := os.LookupEnv("GCE_METADATA_HOST")
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	return func() {
+		server.Close()
+		if set {
+			os.Setenv("GCE_METADATA_HOST", old)
+		} else {
+			os.Unsetenv("GCE_METADATA_HOST")
+		}
+	}
+}
+
+func TestMetadataToken(t *testing.T) {
+	defer fakeMetadataServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			t.Errorf("request without Metadata-Flavor header")
+		}
+		if r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" || r.URL.Query().Get("scopes") != "s1" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+
+		w.Header().Set("Metadata-Flavor", "Google")
+		w.Write([]byte(`{"access_token":"at","token_type":"Bearer","expires_in":3599}`))
+	})()
+
+	token, err := (&metadataSource{scope: "s1"}).Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "at" || token.TokenType != "Bearer" {
+		t.Errorf("token = %+v", token)
+	}
+	if d := token.Expiry.Sub(time.Now()); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expiry in %s, want an hour", d)
+	}
+}
+
+func TestMetadataServiceAccountEmail(t *testing.T) {
+	defer fakeMetadataServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Metadata-Flavor", "Google")
+		w.Write([]byte("sa@project.iam.gserviceaccount.com\n"))
+	})()
+
+	email, err := metadataServiceAccountEmail()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email != "sa@project.iam.gserviceaccount.com" {
+		t.Errorf("email = %q", email)
+	}
+}
+
+func TestMetadataErrors(t *testing.T) {
+	tests := map[string]http.HandlerFunc{
+		"no flavor": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"access_token":"at"}`))
+		},
+		"status": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Metadata-Flavor", "Google")
+			w.WriteHeader(http.StatusNotFound)
+		},
+		"no token": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Metadata-Flavor", "Google")
+			w.Write([]byte(`{}`))
+		},
+	}
+
+	for name, handler := range tests {
+		restore := fakeMetadataServer(t, handler)
+		if _, err := (&metadataSource{scope: "s1"}).Token(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+		restore()
+	}
+}
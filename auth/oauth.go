@@ -3,40 +3,100 @@ package auth
 import (
 	"fmt"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"net/http"
 	"time"
 )
 
-type authCodeFn func(string) func() string
+// Drive scopes, the file scope only gives access to files created or opened by the app
+const (
+	ScopeFull     = "https://www.googleapis.com/auth/drive"
+	ScopeReadonly = "https://www.googleapis.com/auth/drive.readonly"
+	ScopeFile     = "https://www.googleapis.com/auth/drive.file"
+)
 
-func NewFileSourceClient(clientId, clientSecret, tokenFile string, authFn authCodeFn) (*http.Client, error) {
-	conf := getConfig(clientId, clientSecret)
+// Reports whether the scopes allow changing files
+func CanWrite(scopes []string) bool {
+	for _, scope := range scopes {
+		if scope == ScopeFull || scope == ScopeFile {
+			return true
+		}
+	}
+	return false
+}
+
+func NewStoredTokenClient(clientId, clientSecret, scope string, store TokenStore, prompt LoginPrompt) (*http.Client, error) {
+	tokenSource, err := NewStoredTokenSource(clientId, clientSecret, scope, store, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(oauth2.NoContext, tokenSource), nil
+}
+
+func NewStoredTokenSource(clientId, clientSecret, scope string, store TokenStore, prompt LoginPrompt) (oauth2.TokenSource, error) {
+	conf := getConfig(clientId, clientSecret, scope)
 
 	// Read cached token
-	token, exists, err := ReadToken(tokenFile)
+	stored, exists, err := readStoredToken(store)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to read token: %s", err)
 	}
 
-	// Require auth code if token file does not exist
+	// Only the client that issued the token can refresh it
+	if exists && stored.ClientId != "" && stored.ClientId != clientId {
+		return nil, fmt.Errorf("Token in %s was issued to client %s, but client %s is configured. Use the same client or run 'godrive auth login' to authenticate again", store, stored.ClientId, clientId)
+	}
+
+	// Require auth code if token does not exist
 	// or refresh token is missing
-	if !exists || token.RefreshToken == "" {
-		authUrl := conf.AuthCodeURL("state", oauth2.AccessTypeOffline)
-		authCode := authFn(authUrl)()
-		token, err = conf.Exchange(oauth2.NoContext, authCode)
+	if !exists || stored.RefreshToken == "" {
+		token, err := login(conf, prompt)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to exchange auth code for token: %s", err)
+			return nil, err
+		}
+
+		if err := SaveToken(store, token, clientId, tokenScopes(token)); err != nil {
+			return nil, fmt.Errorf("Failed to save token: %s", err)
 		}
+		return StoreSource(store, token, tokenScopes(token), conf), nil
+	}
+
+	return StoreSource(store, stored.Token, stored.Scopes, conf), nil
+}
+
+// Authorizes interactively and saves the token, replacing any existing token
+func Login(clientId, clientSecret, scope string, store TokenStore, prompt LoginPrompt) error {
+	token, err := login(getConfig(clientId, clientSecret, scope), prompt)
+	if err != nil {
+		return err
 	}
 
-	return oauth2.NewClient(
-		oauth2.NoContext,
-		FileSource(tokenFile, token, conf),
-	), nil
+	if err := SaveToken(store, token, clientId, tokenScopes(token)); err != nil {
+		return fmt.Errorf("Failed to save token: %s", err)
+	}
+	return nil
+}
+
+func login(conf *oauth2.Config, prompt LoginPrompt) (*oauth2.Token, error) {
+	var token *oauth2.Token
+	var err error
+
+	if prompt.Device {
+		token, err = DeviceLogin(conf, DeviceCodeUrl, prompt)
+	} else {
+		token, err = LoopbackLogin(conf, prompt)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to authorize: %s", err)
+	}
+	return token, nil
 }
 
 func NewRefreshTokenClient(clientId, clientSecret, refreshToken string) *http.Client {
+	return oauth2.NewClient(oauth2.NoContext, NewRefreshTokenSource(clientId, clientSecret, refreshToken))
+}
+
+func NewRefreshTokenSource(clientId, clientSecret, refreshToken string) oauth2.TokenSource {
 	conf := getConfig(clientId, clientSecret)
 
 	token := &oauth2.Token{
@@ -45,13 +105,14 @@ func NewRefreshTokenClient(clientId, clientSecret, refreshToken string) *http.Cl
 		Expiry:       time.Now(),
 	}
 
-	return oauth2.NewClient(
-		oauth2.NoContext,
-		conf.TokenSource(oauth2.NoContext, token),
-	)
+	return conf.TokenSource(oauth2.NoContext, token)
 }
 
 func NewAccessTokenClient(clientId, clientSecret, accessToken string) *http.Client {
+	return oauth2.NewClient(oauth2.NoContext, NewAccessTokenSource(clientId, clientSecret, accessToken))
+}
+
+func NewAccessTokenSource(clientId, clientSecret, accessToken string) oauth2.TokenSource {
 	conf := getConfig(clientId, clientSecret)
 
 	token := &oauth2.Token{
@@ -59,36 +120,38 @@ func NewAccessTokenClient(clientId, clientSecret, accessToken string) *http.Clie
 		AccessToken: accessToken,
 	}
 
-	return oauth2.NewClient(
-		oauth2.NoContext,
-		conf.TokenSource(oauth2.NoContext, token),
-	)
+	return conf.TokenSource(oauth2.NoContext, token)
+}
+
+func NewServiceAccountClient(serviceAccountFile, subject, scope string) (*http.Client, error) {
+	tokenSource, err := NewServiceAccountTokenSource(serviceAccountFile, subject, scope)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(oauth2.NoContext, tokenSource), nil
 }
 
-func NewServiceAccountClient(serviceAccountFile string, subject string) (*http.Client, error) {
+// The file may also hold an external account config, which
+// gets tokens without a long-lived service account key
+func NewServiceAccountTokenSource(serviceAccountFile, subject, scope string) (oauth2.TokenSource, error) {
 	content, exists, err := ReadFile(serviceAccountFile)
-	if(!exists) {
+	if !exists {
 		return nil, fmt.Errorf("Service account filename %q not found", serviceAccountFile)
 	}
 
-	if(err != nil) {
+	if err != nil {
 		return nil, err
 	}
 
-	conf, err := google.JWTConfigFromJSON(content, "https://www.googleapis.com/auth/drive")
-	if(err != nil) {
-		return nil, err
-	}
-  conf.Subject = subject
-	return conf.Client(oauth2.NoContext), nil
+	return credentialsTokenSource(content, scope, subject)
 }
 
-func getConfig(clientId, clientSecret string) *oauth2.Config {
+// Scopes are only needed to authorize, refreshed tokens keep the scopes they were issued with
+func getConfig(clientId, clientSecret string, scopes ...string) *oauth2.Config {
 	return &oauth2.Config{
 		ClientID:     clientId,
 		ClientSecret: clientSecret,
-		Scopes:       []string{"https://www.googleapis.com/auth/drive"},
-		RedirectURL:  "urn:ietf:wg:oauth:2.0:oob",
+		Scopes:       scopes,
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  "https://accounts.google.com/o/oauth2/auth",
 			TokenURL: "https://accounts.google.com/o/oauth2/token",
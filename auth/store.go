@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Stores the serialized token
+type TokenStore interface {
+	Load() ([]byte, bool, error)
+	Save(content []byte) error
+	Delete() error
+	String() string
+}
+
+// Stores the token as plain json, anyone who can read the file has access to the drive
+type FileStore struct {
+	Path string
+}
+
+func (self FileStore) Load() ([]byte, bool, error) {
+	return ReadFile(self.Path)
+}
+
+func (self FileStore) Save(content []byte) error {
+	return writeFile(self.Path, content)
+}
+
+func (self FileStore) Delete() error {
+	err := os.Remove(self.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (self FileStore) String() string {
+	return self.Path
+}
+
+// Writes to a temp file first and moves it in place
+func writeFile(path string, content []byte) error {
+	if err := mkdir(path); err != nil {
+		return err
+	}
+
+	tmpFile := path + ".tmp"
+	err := ioutil.WriteFile(tmpFile, content, 0600)
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+
+	return os.Rename(tmpFile, path)
+}
+
+// Moves the token to another store if that store has no token.
+// The token is read back from the new store before it is removed
+// from the old one. Returns true if the token was moved
+func MigrateToken(from, to TokenStore) (bool, error) {
+	_, exists, err := to.Load()
+	if err != nil || exists {
+		return false, err
+	}
+
+	content, exists, err := from.Load()
+	if err != nil || !exists {
+		return false, err
+	}
+
+	if err := to.Save(content); err != nil {
+		return false, err
+	}
+
+	saved, _, err := to.Load()
+	if err != nil {
+		return false, err
+	}
+	if string(saved) != string(content) {
+		return false, fmt.Errorf("Token read back from %s does not match", to)
+	}
+
+	return true, from.Delete()
+}
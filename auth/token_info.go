@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const TokenInfoUrl = "https://oauth2.googleapis.com/tokeninfo"
+const RevokeUrl = "https://oauth2.googleapis.com/revoke"
+
+// Returned by RevokeToken when the token is already expired or revoked
+var ErrInvalidToken = fmt.Errorf("Token is already invalid")
+
+type TokenInfo struct {
+	Scopes []string
+	Expiry time.Time
+	Email  string
+}
+
+// Google returns expires_in as a string, it is a number in the standard
+type tokenInfoResponse struct {
+	Scope            string      `json:"scope"`
+	ExpiresIn        interface{} `json:"expires_in"`
+	Email            string      `json:"email"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+// Returns the scopes and expiry of an access token
+func GetTokenInfo(accessToken string) (*TokenInfo, error) {
+	res, err := http.Get(TokenInfoUrl + "?" + url.Values{"access_token": {accessToken}}.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get token info: %s", err)
+	}
+	defer res.Body.Close()
+
+	var body tokenInfoResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("Failed to decode token info (status %d): %s", res.StatusCode, err)
+	}
+
+	if body.Error != "" {
+		return nil, fmt.Errorf("Failed to get token info: %s", tokenError{body.Error, body.ErrorDescription})
+	}
+
+	info := &TokenInfo{
+		Scopes: strings.Fields(body.Scope),
+		Email:  body.Email,
+	}
+
+	if seconds, err := strconv.ParseInt(fmt.Sprint(body.ExpiresIn), 10, 64); err == nil {
+		info.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+
+	return info, nil
+}
+
+// Revokes an access or refresh token, revoking a refresh
+// token also revokes the access tokens issued with it
+func RevokeToken(token string) error {
+	res, err := http.PostForm(RevokeUrl, url.Values{"token": {token}})
+	if err != nil {
+		return fmt.Errorf("Failed to revoke token: %s", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var body tokenResponse
+	json.NewDecoder(res.Body).Decode(&body)
+
+	if body.Error == "invalid_token" {
+		return ErrInvalidToken
+	}
+
+	if body.Error != "" {
+		return fmt.Errorf("Failed to revoke token: %s", tokenError{body.Error, body.ErrorDescription})
+	}
+	return fmt.Errorf("Failed to revoke token, status %d", res.StatusCode)
+}
@@ -0,0 +1,216 @@
+//go:build !plan9
+// +build !plan9
+
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+
+	"go.etcd.io/bbolt"
+	"google.golang.org/api/drive/v3"
+)
+
+var (
+	filesBucket      = []byte("files")
+	listingsBucket   = []byte("listings")
+	pageTokensBucket = []byte("pageTokens")
+)
+
+// Cache of local file checksums and remote sync root listings stored in a
+// bolt database. Within a process reads are concurrent and each update is
+// a transaction. Bolt locks the file while a process has it open for
+// writing, and a read-only open waits for that lock as well, so other
+// processes get ErrInUse and are expected to run without a cache
+type Cache struct {
+	db *bbolt.DB
+}
+
+func Open(path string) (*Cache, error) {
+	if err := mkdir(path); err != nil {
+		return nil, err
+	}
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: OpenTimeout})
+	if err == bbolt.ErrTimeout {
+		return nil, ErrInUse
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{filesBucket, listingsBucket, pageTokensBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Cache{db}, nil
+}
+
+func (self *Cache) Close() error {
+	return self.db.Close()
+}
+
+// Returns the cached info of a local file, or nil if it is not cached
+func (self *Cache) File(path string) (*FileInfo, error) {
+	var info *FileInfo
+
+	err := self.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(filesBucket).Get([]byte(path))
+		if data == nil {
+			return nil
+		}
+
+		info = &FileInfo{}
+		return json.Unmarshal(data, info)
+	})
+
+	return info, err
+}
+
+func (self *Cache) PutFile(path string, info *FileInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	return self.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(filesBucket).Put([]byte(path), data)
+	})
+}
+
+// Removes cached info of files below root that no longer exist
+func (self *Cache) PruneFiles(root string) (int, error) {
+	var pruned int
+
+	err := self.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(filesBucket)
+		prefix := []byte(root)
+
+		// Keys are collected first as deleting moves the cursor
+		var stale [][]byte
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if _, err := os.Stat(string(k)); os.IsNotExist(err) {
+				stale = append(stale, append([]byte{}, k...))
+			}
+		}
+
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		pruned = len(stale)
+		return nil
+	})
+
+	return pruned, err
+}
+
+// Returns the cached files of a sync root and the page token to list
+// changes from. The page token is empty if the root is not cached
+func (self *Cache) Listing(rootId string) ([]*drive.File, string, error) {
+	var files []*drive.File
+	var pageToken string
+
+	err := self.db.View(func(tx *bbolt.Tx) error {
+		pageToken = string(tx.Bucket(pageTokensBucket).Get([]byte(rootId)))
+
+		b := tx.Bucket(listingsBucket).Bucket([]byte(rootId))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			f := &drive.File{}
+			if err := json.Unmarshal(v, f); err != nil {
+				return err
+			}
+			files = append(files, f)
+			return nil
+		})
+	})
+
+	return files, pageToken, err
+}
+
+// Replaces the cached files of a sync root
+func (self *Cache) PutListing(rootId, pageToken string, files []*drive.File) error {
+	return self.db.Update(func(tx *bbolt.Tx) error {
+		listings := tx.Bucket(listingsBucket)
+
+		err := listings.DeleteBucket([]byte(rootId))
+		if err != nil && err != bbolt.ErrBucketNotFound {
+			return err
+		}
+
+		b, err := listings.CreateBucket([]byte(rootId))
+		if err != nil {
+			return err
+		}
+
+		if err := putFiles(b, files); err != nil {
+			return err
+		}
+
+		return tx.Bucket(pageTokensBucket).Put([]byte(rootId), []byte(pageToken))
+	})
+}
+
+// Applies changed and removed files to the cached listing of a sync root
+func (self *Cache) UpdateListing(rootId, pageToken string, changed []*drive.File, removed []string) error {
+	return self.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.Bucket(listingsBucket).CreateBucketIfNotExists([]byte(rootId))
+		if err != nil {
+			return err
+		}
+
+		for _, id := range removed {
+			if err := b.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+
+		if err := putFiles(b, changed); err != nil {
+			return err
+		}
+
+		return tx.Bucket(pageTokensBucket).Put([]byte(rootId), []byte(pageToken))
+	})
+}
+
+// Removes the cached listing of a sync root
+func (self *Cache) DeleteListing(rootId string) error {
+	return self.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(listingsBucket).DeleteBucket([]byte(rootId))
+		if err != nil && err != bbolt.ErrBucketNotFound {
+			return err
+		}
+		return tx.Bucket(pageTokensBucket).Delete([]byte(rootId))
+	})
+}
+
+func putFiles(b *bbolt.Bucket, files []*drive.File) error {
+	for _, f := range files {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+
+		if err := b.Put([]byte(f.Id), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+//go:build !plan9
+// +build !plan9
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func openTempCache(t *testing.T) (*Cache, string, func()) {
+	dir, err := ioutil.TempDir("", "godrive-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "cache.db")
+	c, err := Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return c, path, func() { c.Close(); os.RemoveAll(dir) }
+}
+
+func TestListing(t *testing.T) {
+	c, _, cleanup := openTempCache(t)
+	defer cleanup()
+
+	file := func(id, name string) *drive.File {
+		return &drive.File{Id: id, Name: name}
+	}
+
+	tests := []struct {
+		name      string
+		put       []*drive.File
+		changed   []*drive.File
+		removed   []string
+		pageToken string
+		want      []string
+	}{
+		{"put", []*drive.File{file("a", "a.txt"), file("b", "b.txt")}, nil, nil, "1", []string{"a:a.txt", "b:b.txt"}},
+		{"rename", nil, []*drive.File{file("a", "c.txt")}, nil, "2", []string{"a:c.txt", "b:b.txt"}},
+		{"add and remove", nil, []*drive.File{file("d", "d.txt")}, []string{"b", "unknown"}, "3", []string{"a:c.txt", "d:d.txt"}},
+		{"replace", []*drive.File{file("e", "e.txt")}, nil, nil, "4", []string{"e:e.txt"}},
+	}
+
+	for _, test := range tests {
+		var err error
+		if test.put != nil {
+			err = c.PutListing("root", test.pageToken, test.put)
+		} else {
+			err = c.UpdateListing("root", test.pageToken, test.changed, test.removed)
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		files, pageToken, err := c.Listing("root")
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		var got []string
+		for _, f := range files {
+			got = append(got, f.Id+":"+f.Name)
+		}
+		sort.Strings(got)
+
+		if !reflect.DeepEqual(got, test.want) || pageToken != test.pageToken {
+			t.Errorf("%s: Listing() = %v, %q, want %v, %q", test.name, got, pageToken, test.want, test.pageToken)
+		}
+	}
+
+	if err := c.DeleteListing("root"); err != nil {
+		t.Fatal(err)
+	}
+	if files, pageToken, err := c.Listing("root"); err != nil || files != nil || pageToken != "" {
+		t.Errorf("Listing() after delete = %v, %q, %v, want nothing", files, pageToken, err)
+	}
+}
+
+func TestPruneFiles(t *testing.T) {
+	c, path, cleanup := openTempCache(t)
+	defer cleanup()
+
+	dir := filepath.Dir(path)
+	existing := filepath.Join(dir, "sync", "a.txt")
+	os.MkdirAll(filepath.Dir(existing), 0700)
+	ioutil.WriteFile(existing, []byte("a"), 0600)
+
+	tests := []struct {
+		path string
+		kept bool
+	}{
+		{existing, true},
+		{filepath.Join(dir, "sync", "gone.txt"), false},
+		{filepath.Join(dir, "other", "gone.txt"), true},
+	}
+
+	for _, test := range tests {
+		if err := c.PutFile(test.path, &FileInfo{Size: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := c.PruneFiles(filepath.Join(dir, "sync"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Errorf("PruneFiles() = %d, want 1", pruned)
+	}
+
+	for _, test := range tests {
+		info, err := c.File(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (info != nil) != test.kept {
+			t.Errorf("File(%q) cached %v, want %v", test.path, info != nil, test.kept)
+		}
+	}
+}
+
+// Bolt locks the database while it is open, so only one process can use it
+func TestOpenInUse(t *testing.T) {
+	_, path, cleanup := openTempCache(t)
+	defer cleanup()
+
+	if c, err := Open(path); err != ErrInUse {
+		if err == nil {
+			c.Close()
+		}
+		t.Errorf("second Open() = %v, want %v", err, ErrInUse)
+	}
+}
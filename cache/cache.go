@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Time to wait for another godrive process to release the cache
+const OpenTimeout = time.Second
+
+// Returned by Open when another godrive process did not release the cache in time
+var ErrInUse = errors.New("Cache is in use by another godrive process")
+
+// Cached information about a local file, checksums are keyed by algorithm name
+type FileInfo struct {
+	Size      int64             `json:"size"`
+	Modified  int64             `json:"modified"`
+	Checksums map[string]string `json:"checksums"`
+}
+
+func mkdir(path string) error {
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	return os.MkdirAll(dir, 0700)
+}
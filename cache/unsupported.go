@@ -0,0 +1,52 @@
+//go:build plan9
+// +build plan9
+
+package cache
+
+import (
+	"errors"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// Bolt does not support plan9, callers are expected
+// to continue without a cache when Open fails
+var ErrUnsupported = errors.New("Cache is not supported on this platform")
+
+type Cache struct{}
+
+func Open(path string) (*Cache, error) {
+	return nil, ErrUnsupported
+}
+
+func (self *Cache) Close() error {
+	return ErrUnsupported
+}
+
+func (self *Cache) File(path string) (*FileInfo, error) {
+	return nil, ErrUnsupported
+}
+
+func (self *Cache) PutFile(path string, info *FileInfo) error {
+	return ErrUnsupported
+}
+
+func (self *Cache) PruneFiles(root string) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (self *Cache) Listing(rootId string) ([]*drive.File, string, error) {
+	return nil, "", ErrUnsupported
+}
+
+func (self *Cache) PutListing(rootId, pageToken string, files []*drive.File) error {
+	return ErrUnsupported
+}
+
+func (self *Cache) UpdateListing(rootId, pageToken string, changed []*drive.File, removed []string) error {
+	return ErrUnsupported
+}
+
+func (self *Cache) DeleteListing(rootId string) error {
+	return ErrUnsupported
+}
@@ -46,6 +46,8 @@ func (self *Handler) getParser() Parser {
 			groupName := flagGroupName(pattern)
 			flags := self.FlagGroups.getFlags(groupName)
 			parsers = append(parsers, getFlagParser(flags))
+		} else if isVariadicCaptureGroup(pattern) {
+			parsers = append(parsers, VariadicCaptureGroupParser{pattern})
 		} else if isCaptureGroup(pattern) {
 			parsers = append(parsers, CaptureGroupParser{pattern})
 		} else {
@@ -58,7 +60,7 @@ func (self *Handler) getParser() Parser {
 
 // Split on spaces but ignore spaces inside <...> and [...]
 func (self *Handler) SplitPattern() []string {
-	re := regexp.MustCompile(`(<[^>]+>|\[[^\]]+]|\S+)`)
+	re := regexp.MustCompile(`(<[^>]+>(?:\.\.\.)?|\[[^\]]+]|\S+)`)
 	matches := []string{}
 
 	for _, value := range re.FindAllStringSubmatch(self.Pattern, -1) {
@@ -109,6 +111,10 @@ func isCaptureGroup(arg string) bool {
 	return strings.HasPrefix(arg, "<") && strings.HasSuffix(arg, ">")
 }
 
+func isVariadicCaptureGroup(arg string) bool {
+	return strings.HasPrefix(arg, "<") && strings.HasSuffix(arg, ">...")
+}
+
 func isFlagGroup(arg string) bool {
 	return strings.HasPrefix(arg, "[") && strings.HasSuffix(arg, "]")
 }
@@ -63,6 +63,28 @@ func (self CaptureGroupParser) String() string {
 	return fmt.Sprintf("CaptureGroupParser '%s'", self.value)
 }
 
+// Captures all remaining values, including none,
+// must be the last part of a pattern
+type VariadicCaptureGroupParser struct {
+	value string
+}
+
+func (self VariadicCaptureGroupParser) Match(values []string) ([]string, bool) {
+	return nil, true
+}
+
+func (self VariadicCaptureGroupParser) key() string {
+	return self.value[1 : len(self.value)-4]
+}
+
+func (self VariadicCaptureGroupParser) Capture(values []string) ([]string, map[string]interface{}) {
+	return nil, map[string]interface{}{self.key(): copySlice(values)}
+}
+
+func (self VariadicCaptureGroupParser) String() string {
+	return fmt.Sprintf("VariadicCaptureGroupParser '%s'", self.value)
+}
+
 type BoolFlagParser struct {
 	pattern      string
 	key          string
@@ -1,75 +1,79 @@
 package main
 
 import (
-	"encoding/json"
-	"os"
+	"log"
 
+	"github.com/nanometrics/godrive/cache"
 	"github.com/nanometrics/godrive/drive"
 )
 
 const MinCacheFileSize = 5 * 1024 * 1024
 
-type Md5Comparer struct{}
+type ChecksumComparer struct {
+	Hash drive.HashAlgorithm
+}
 
-func (self Md5Comparer) Changed(local *drive.LocalFile, remote *drive.RemoteFile) bool {
-	return remote.Md5() != drive.Md5sum(local.AbsPath())
+func (self ChecksumComparer) Changed(local *drive.LocalFile, remote *drive.RemoteFile) bool {
+	return remote.Checksum(self.Hash) != drive.Checksum(local.AbsPath(), self.Hash)
 }
 
-type CachedFileInfo struct {
-	Size     int64  `json:"size"`
-	Modified int64  `json:"modified"`
-	Md5      string `json:"md5"`
+func (self ChecksumComparer) Algorithm() drive.HashAlgorithm {
+	return self.Hash
 }
 
-func NewCachedMd5Comparer(path string) CachedMd5Comparer {
-	cache := map[string]*CachedFileInfo{}
+// Compares files by checksum, the checksums of large local files are
+// cached until their size or modification time changes. Works as a
+// ChecksumComparer if the cache is nil
+func NewCachedChecksumComparer(c *cache.Cache, algo drive.HashAlgorithm) CachedChecksumComparer {
+	return CachedChecksumComparer{c, algo}
+}
 
-	f, err := os.Open(path)
-	if err == nil {
-		json.NewDecoder(f).Decode(&cache)
-	}
-	f.Close()
-	return CachedMd5Comparer{path, cache}
+type CachedChecksumComparer struct {
+	cache     *cache.Cache
+	algorithm drive.HashAlgorithm
 }
 
-type CachedMd5Comparer struct {
-	path  string
-	cache map[string]*CachedFileInfo
+func (self CachedChecksumComparer) Changed(local *drive.LocalFile, remote *drive.RemoteFile) bool {
+	return remote.Checksum(self.algorithm) != self.checksum(local, self.algorithm)
 }
 
-func (self CachedMd5Comparer) Changed(local *drive.LocalFile, remote *drive.RemoteFile) bool {
-	return remote.Md5() != self.md5(local)
+func (self CachedChecksumComparer) Algorithm() drive.HashAlgorithm {
+	return self.algorithm
 }
 
-func (self CachedMd5Comparer) md5(local *drive.LocalFile) string {
-	// See if file exist in cache
-	cached, found := self.cache[local.AbsPath()]
+func (self CachedChecksumComparer) checksum(local *drive.LocalFile, algo drive.HashAlgorithm) string {
+	if self.cache == nil || local.Size() <= MinCacheFileSize {
+		return drive.Checksum(local.AbsPath(), algo)
+	}
 
-	// If found and modification time and size has not changed, return cached md5
-	if found && local.Modified().UnixNano() == cached.Modified && local.Size() == cached.Size {
-		return cached.Md5
+	cached, err := self.cache.File(local.AbsPath())
+	if err != nil {
+		log.Printf("Failed to read file cache: %s\n", err)
 	}
 
-	// Calculate new md5 sum
-	md5 := drive.Md5sum(local.AbsPath())
+	// Other checksums of the file are kept as long as it is unchanged
+	if cached == nil || local.Modified().UnixNano() != cached.Modified || local.Size() != cached.Size {
+		cached = &cache.FileInfo{
+			Size:      local.Size(),
+			Modified:  local.Modified().UnixNano(),
+			Checksums: map[string]string{},
+		}
+	}
 
-	// Cache file info if file meets size criteria
-	if local.Size() > MinCacheFileSize {
-		self.cacheAdd(local, md5)
-		self.persist()
+	if cached.Checksums == nil {
+		cached.Checksums = map[string]string{}
 	}
 
-	return md5
-}
+	if sum, found := cached.Checksums[algo.String()]; found {
+		return sum
+	}
 
-func (self CachedMd5Comparer) cacheAdd(lf *drive.LocalFile, md5 string) {
-	self.cache[lf.AbsPath()] = &CachedFileInfo{
-		Size:     lf.Size(),
-		Modified: lf.Modified().UnixNano(),
-		Md5:      md5,
+	sum := drive.Checksum(local.AbsPath(), algo)
+	cached.Checksums[algo.String()] = sum
+
+	if err := self.cache.PutFile(local.AbsPath(), cached); err != nil {
+		log.Printf("Failed to update file cache: %s\n", err)
 	}
-}
 
-func (self CachedMd5Comparer) persist() {
-	writeJson(self.path, self.cache)
+	return sum
 }
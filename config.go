@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+)
+
+const ConfigFilename = "config.toml"
+const DefaultProfileName = "default"
+
+const (
+	AuthOauth          = "oauth"
+	AuthServiceAccount = "service-account"
+	AuthDefault        = "application-default"
+)
+
+const (
+	ScopeFull     = "full"
+	ScopeReadonly = "readonly"
+	ScopeFile     = "file"
+)
+
+const (
+	TokenStoreFile      = "file"
+	TokenStoreKeyring   = "keyring"
+	TokenStoreEncrypted = "encrypted"
+)
+
+type Config struct {
+	DefaultProfile string              `toml:"default_profile,omitempty"`
+	Profiles       map[string]*Profile `toml:"profiles,omitempty"`
+}
+
+type Profile struct {
+	Auth           string               `toml:"auth,omitempty"`
+	ServiceAccount string               `toml:"service_account,omitempty"`
+	Subject        string               `toml:"subject,omitempty"`
+	TokenStore     string               `toml:"token_store,omitempty"`
+	Scope          string               `toml:"scope,omitempty"`
+	SharedDrive    string               `toml:"shared_drive,omitempty"`
+	ChunkSize      int64                `toml:"chunk_size,omitempty"`
+	Timeout        int64                `toml:"timeout,omitempty"`
+	Parallelism    int64                `toml:"parallelism,omitempty"`
+	Sync           map[string]*SyncPair `toml:"sync,omitempty"`
+}
+
+type SyncPair struct {
+	Path   string `toml:"path"`
+	FileId string `toml:"file_id"`
+}
+
+func readConfig(path string) (*Config, error) {
+	config := &Config{}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return config, nil
+	}
+
+	if _, err := toml.DecodeFile(path, config); err != nil {
+		return nil, fmt.Errorf("Failed to read config file %s: %s", path, err)
+	}
+
+	return config, nil
+}
+
+func writeConfig(path string, config *Config) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmpFile := path + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = toml.NewEncoder(f).Encode(config)
+	f.Close()
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+
+	return os.Rename(tmpFile, path)
+}
+
+// Finds the config dir and profile name among the raw arguments,
+// they are needed before the arguments are parsed
+func profileLocation(args []string) (string, string) {
+	configDir := DefaultConfigDir
+	profileName := ""
+
+	for i := 0; i < len(args)-1; i++ {
+		switch args[i] {
+		case "-c", "--config":
+			configDir = args[i+1]
+		case "--profile":
+			profileName = args[i+1]
+		}
+	}
+
+	if os.Getenv("GODRIVE_CONFIG_DIR") != "" {
+		configDir = os.Getenv("GODRIVE_CONFIG_DIR")
+	}
+
+	return configDir, profileName
+}
+
+// Loads the selected profile before the arguments are parsed, the values
+// are used as flag defaults so flags on the command line take precedence
+func loadProfile(args []string) *Profile {
+	configDir, profileName := profileLocation(args)
+
+	config, err := readConfig(ConfigFilePath(configDir, ConfigFilename))
+	if err != nil {
+		ExitF("%s", err)
+	}
+
+	name := selectedProfileName(config, profileName)
+	p, found := config.Profiles[name]
+	if !found {
+		p = &Profile{}
+	}
+
+	// The config commands must work with missing or invalid profiles to be able to fix them
+	if isConfigCommand(args) {
+		return p.withDefaults()
+	}
+
+	if !found && profileName != "" {
+		ExitF("Profile '%s' not found in %s", profileName, ConfigFilePath(configDir, ConfigFilename))
+	}
+
+	if err := p.validate(); err != nil {
+		ExitF("Invalid profile '%s': %s", name, err)
+	}
+
+	return p.withDefaults()
+}
+
+func isConfigCommand(args []string) bool {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == "config" && (args[i+1] == "show" || args[i+1] == "set") {
+			return true
+		}
+	}
+	return false
+}
+
+func selectedProfileName(config *Config, name string) string {
+	if name != "" {
+		return name
+	}
+	if config.DefaultProfile != "" {
+		return config.DefaultProfile
+	}
+	return DefaultProfileName
+}
+
+func (self *Profile) validate() error {
+	switch self.Auth {
+	case "", AuthOauth, AuthDefault:
+	case AuthServiceAccount:
+		if self.ServiceAccount == "" {
+			return fmt.Errorf("auth '%s' requires service_account", AuthServiceAccount)
+		}
+	default:
+		return fmt.Errorf("unknown auth '%s', must be %s, %s or %s", self.Auth, AuthOauth, AuthServiceAccount, AuthDefault)
+	}
+
+	switch self.Scope {
+	case "", ScopeFull, ScopeReadonly, ScopeFile:
+	default:
+		return fmt.Errorf("unknown scope '%s', must be %s, %s or %s", self.Scope, ScopeReadonly, ScopeFile, ScopeFull)
+	}
+
+	return validateTokenStore(self.TokenStore)
+}
+
+func validateTokenStore(name string) error {
+	switch name {
+	case "", TokenStoreFile, TokenStoreKeyring, TokenStoreEncrypted:
+		return nil
+	}
+	return fmt.Errorf("unknown token_store '%s', must be %s, %s or %s", name, TokenStoreFile, TokenStoreKeyring, TokenStoreEncrypted)
+}
+
+// Returns a copy of the profile with unset values replaced by the defaults
+func (self *Profile) withDefaults() *Profile {
+	p := *self
+
+	if p.ChunkSize == 0 {
+		p.ChunkSize = DefaultUploadChunkSize
+	}
+	if p.Timeout == 0 {
+		p.Timeout = DefaultTimeout
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = DefaultParallelism
+	}
+
+	// An explicit oauth profile ignores the service account
+	if p.Auth == AuthOauth {
+		p.ServiceAccount = ""
+		p.Subject = ""
+	}
+
+	// Application default credentials are found without a service account file
+	if p.Auth == AuthDefault {
+		p.ServiceAccount = ""
+	}
+
+	return &p
+}
+
+// Default parents for new files, the root of the shared drive if set
+func (self *Profile) parents() []string {
+	if self.SharedDrive == "" {
+		return nil
+	}
+	return []string{self.SharedDrive}
+}
+
+func (self *Profile) set(key, value string) error {
+	parts := strings.Split(key, ".")
+
+	// Sync pairs are set as sync.<name>.path and sync.<name>.file_id
+	if len(parts) == 3 && parts[0] == "sync" {
+		return self.setSyncPair(parts[1], parts[2], value)
+	}
+
+	switch key {
+	case "auth":
+		self.Auth = value
+	case "service_account":
+		self.ServiceAccount = value
+	case "subject":
+		self.Subject = value
+	case "token_store":
+		self.TokenStore = value
+	case "scope":
+		self.Scope = value
+	case "shared_drive":
+		self.SharedDrive = value
+	case "chunk_size", "timeout", "parallelism":
+		n, err := parseConfigInt(key, value)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "chunk_size":
+			self.ChunkSize = n
+		case "timeout":
+			self.Timeout = n
+		case "parallelism":
+			self.Parallelism = n
+		}
+	default:
+		return fmt.Errorf("Unknown config key '%s'", key)
+	}
+
+	return self.validate()
+}
+
+func (self *Profile) setSyncPair(name, field, value string) error {
+	if self.Sync == nil {
+		self.Sync = map[string]*SyncPair{}
+	}
+
+	pair, found := self.Sync[name]
+	if !found {
+		pair = &SyncPair{}
+		self.Sync[name] = pair
+	}
+
+	switch field {
+	case "path":
+		pair.Path = value
+	case "file_id":
+		pair.FileId = value
+	default:
+		return fmt.Errorf("Unknown sync pair key '%s', must be path or file_id", field)
+	}
+
+	// Remove pair when both values are cleared
+	if pair.Path == "" && pair.FileId == "" {
+		delete(self.Sync, name)
+	}
+
+	return nil
+}
+
+// An empty value resets the key to its default
+func parseConfigInt(key, value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Invalid value '%s' for %s, must be a positive integer", value, key)
+	}
+	return n, nil
+}
+
+func printProfile(out io.Writer, name string, p *Profile) {
+	fmt.Fprintf(out, "Profile: %s\n", name)
+
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 0, 3, ' ', 0)
+
+	items := []struct {
+		key   string
+		value string
+	}{
+		{"auth", p.Auth},
+		{"service_account", p.ServiceAccount},
+		{"subject", p.Subject},
+		{"token_store", p.TokenStore},
+		{"scope", p.Scope},
+		{"shared_drive", p.SharedDrive},
+		{"chunk_size", strconv.FormatInt(p.ChunkSize, 10)},
+		{"timeout", strconv.FormatInt(p.Timeout, 10)},
+		{"parallelism", strconv.FormatInt(p.Parallelism, 10)},
+	}
+
+	for _, item := range items {
+		if item.value != "" {
+			fmt.Fprintf(w, "%s\t%s\n", item.key, item.value)
+		}
+	}
+
+	var names []string
+	for name := range p.Sync {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "sync.%s.path\t%s\n", name, p.Sync[name].Path)
+		fmt.Fprintf(w, "sync.%s.file_id\t%s\n", name, p.Sync[name].FileId)
+	}
+
+	w.Flush()
+}
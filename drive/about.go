@@ -28,6 +28,15 @@ func (self *Drive) About(args AboutArgs) (err error) {
 	return
 }
 
+// Returns the email address of the authenticated user
+func (self *Drive) UserEmail() (string, error) {
+	about, err := self.service.About.Get().Fields("user").Do()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get about: %s", err)
+	}
+	return about.User.EmailAddress, nil
+}
+
 type AboutImportArgs struct {
 	Out io.Writer
 }
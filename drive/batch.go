@@ -0,0 +1,171 @@
+package drive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+const BatchUrl = "https://www.googleapis.com/batch/drive/v3"
+
+// Max number of requests drive accepts in a single batch
+const MaxBatchSize = 100
+
+// Applies the same metadata patch to a list of files using batch requests.
+// Requests that fail with a backend or rate limit error are retried
+func (self *Drive) batchPatchFiles(ids []string, patch map[string]interface{}, try int) error {
+	var retry []string
+
+	for start := 0; start < len(ids); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		errs, err := self.doBatchPatch(batch, patch)
+		if err != nil {
+			return fmt.Errorf("Failed to send batch request: %s", err)
+		}
+
+		for i, err := range errs {
+			if err == nil {
+				continue
+			}
+			if isBackendOrRateLimitError(err) && try < MaxErrorRetries {
+				retry = append(retry, batch[i])
+				continue
+			}
+			return fmt.Errorf("Failed to update file %s: %s", batch[i], err)
+		}
+	}
+
+	if len(retry) > 0 {
+		exponentialBackoffSleep(try)
+		return self.batchPatchFiles(retry, patch, try+1)
+	}
+
+	return nil
+}
+
+// Sends a single batch request and returns the error of each part
+func (self *Drive) doBatchPatch(ids []string, patch map[string]interface{}) ([]error, error) {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []batchRequest
+	for _, id := range ids {
+		requests = append(requests, batchRequest{
+			method: "PATCH",
+			path:   fmt.Sprintf("/drive/v3/files/%s?supportsTeamDrives=true&fields=id", url.PathEscape(id)),
+			body:   body,
+		})
+	}
+
+	return self.doBatch(requests)
+}
+
+// A request in a batch, path is relative to the api host.
+// The response body is decoded into result if it is set
+type batchRequest struct {
+	method string
+	path   string
+	body   []byte
+	result interface{}
+}
+
+// Sends up to MaxBatchSize requests in one batch and returns the error of each part
+func (self *Drive) doBatch(requests []batchRequest) ([]error, error) {
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+
+	for i, r := range requests {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "application/http")
+		header.Set("Content-ID", fmt.Sprintf("<item%d>", i))
+
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(pw, "%s %s HTTP/1.1\r\n", r.method, r.path)
+		if r.body != nil {
+			fmt.Fprintf(pw, "Content-Type: application/json\r\n")
+			fmt.Fprintf(pw, "Content-Length: %d\r\n", len(r.body))
+		}
+		fmt.Fprintf(pw, "\r\n")
+		pw.Write(r.body)
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", BatchUrl, buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+
+	res, err := self.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	_, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid batch response: %s", err)
+	}
+
+	// Parts without a response are treated as failed
+	errs := make([]error, len(requests))
+	for i := range errs {
+		errs[i] = &googleapi.Error{Code: http.StatusServiceUnavailable, Message: "No response in batch"}
+	}
+
+	mr := multipart.NewReader(res.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+
+		// Response ids are on the form <response-item{n}>
+		contentId := part.Header.Get("Content-ID")
+		index, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(contentId, "<response-item"), ">"))
+		if err != nil || index < 0 || index >= len(requests) {
+			continue
+		}
+
+		partRes, err := http.ReadResponse(bufio.NewReader(part), req)
+		if err != nil {
+			continue
+		}
+
+		errs[index] = googleapi.CheckResponse(partRes)
+		if errs[index] == nil && requests[index].result != nil {
+			errs[index] = json.NewDecoder(partRes.Body).Decode(requests[index].result)
+		}
+		partRes.Body.Close()
+	}
+
+	return errs, nil
+}
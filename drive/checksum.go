@@ -0,0 +1,219 @@
+package drive
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/drive/v3"
+)
+
+type HashAlgorithm int
+
+const (
+	Md5 HashAlgorithm = iota
+	Sha1
+	Sha256
+)
+
+var hashAlgorithmNames = map[HashAlgorithm]string{
+	Md5:    "md5",
+	Sha1:   "sha1",
+	Sha256: "sha256",
+}
+
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	for algo, algoName := range hashAlgorithmNames {
+		if strings.ToLower(name) == algoName {
+			return algo, nil
+		}
+	}
+	return Md5, fmt.Errorf("Unknown hash algorithm '%s', must be one of md5, sha1 or sha256", name)
+}
+
+func (self HashAlgorithm) String() string {
+	return hashAlgorithmNames[self]
+}
+
+func (self HashAlgorithm) newHash() hash.Hash {
+	switch self {
+	case Sha1:
+		return sha1.New()
+	case Sha256:
+		return sha256.New()
+	}
+	return md5.New()
+}
+
+// Checksums drive has for the content of a file. The vendored drive api
+// predates the sha1Checksum and sha256Checksum fields, so they are fetched
+// with raw requests and decoded into this type instead of drive.File
+type remoteChecksums struct {
+	Md5Checksum    string `json:"md5Checksum"`
+	Sha1Checksum   string `json:"sha1Checksum"`
+	Sha256Checksum string `json:"sha256Checksum"`
+}
+
+func (self remoteChecksums) get(algo HashAlgorithm) string {
+	switch algo {
+	case Sha1:
+		return self.Sha1Checksum
+	case Sha256:
+		return self.Sha256Checksum
+	}
+	return self.Md5Checksum
+}
+
+// Fetches the checksums of files with batch requests, keyed by file id.
+// Requests that fail with a backend or rate limit error are retried
+func (self *Drive) fileChecksums(ids []string, try int) (map[string]remoteChecksums, error) {
+	sums := map[string]remoteChecksums{}
+	var retry []string
+
+	for start := 0; start < len(ids); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		results := make([]remoteChecksums, len(batch))
+		var requests []batchRequest
+		for i, id := range batch {
+			requests = append(requests, batchRequest{
+				method: "GET",
+				path:   fmt.Sprintf("/drive/v3/files/%s?supportsTeamDrives=true&fields=md5Checksum,sha1Checksum,sha256Checksum", url.PathEscape(id)),
+				result: &results[i],
+			})
+		}
+
+		errs, err := self.doBatch(requests)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to send batch request: %s", err)
+		}
+
+		for i, err := range errs {
+			if err == nil {
+				sums[batch[i]] = results[i]
+				continue
+			}
+			if isBackendOrRateLimitError(err) && try < MaxErrorRetries {
+				retry = append(retry, batch[i])
+				continue
+			}
+			return nil, fmt.Errorf("Failed to get checksums of file %s: %s", batch[i], err)
+		}
+	}
+
+	if len(retry) > 0 {
+		exponentialBackoffSleep(try)
+		retried, err := self.fileChecksums(retry, try+1)
+		if err != nil {
+			return nil, err
+		}
+		for id, sum := range retried {
+			sums[id] = sum
+		}
+	}
+
+	return sums, nil
+}
+
+// Returns the checksum drive has for the file. Only the md5 checksum is
+// part of drive.File, the others are fetched. There is no fallback to
+// another algorithm, it fails if drive does not have the checksum
+func (self *Drive) remoteChecksum(f *drive.File, algo HashAlgorithm) (string, error) {
+	sum := f.Md5Checksum
+
+	if algo != Md5 {
+		sums, err := self.fileChecksums([]string{f.Id}, 0)
+		if err != nil {
+			return "", err
+		}
+		sum = sums[f.Id].get(algo)
+	}
+
+	if sum == "" {
+		return "", fmt.Errorf("Drive has no %s checksum for %s (%s)", algo, f.Name, f.Id)
+	}
+	return sum, nil
+}
+
+func uniqueAlgorithms(algos ...HashAlgorithm) []HashAlgorithm {
+	seen := map[HashAlgorithm]bool{}
+	var unique []HashAlgorithm
+
+	for _, algo := range algos {
+		if !seen[algo] {
+			seen[algo] = true
+			unique = append(unique, algo)
+		}
+	}
+
+	return unique
+}
+
+func Checksum(path string, algo HashAlgorithm) string {
+	return Checksums(path, []HashAlgorithm{algo})[algo]
+}
+
+// Calculates the checksums of a local file for all given algorithms.
+// The file is read once, each chunk is hashed by all algorithms at the same time
+func Checksums(path string, algos []HashAlgorithm) map[HashAlgorithm]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return map[HashAlgorithm]string{}
+	}
+	defer f.Close()
+
+	hashes := make([]hash.Hash, len(algos))
+	for i, algo := range algos {
+		hashes[i] = algo.newHash()
+	}
+
+	buf := make([]byte, checksumChunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			writeChunk(hashes, buf[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return map[HashAlgorithm]string{}
+		}
+	}
+
+	sums := map[HashAlgorithm]string{}
+	for i, algo := range algos {
+		sums[algo] = fmt.Sprintf("%x", hashes[i].Sum(nil))
+	}
+	return sums
+}
+
+const checksumChunkSize = 4 * 1024 * 1024
+
+func writeChunk(hashes []hash.Hash, chunk []byte) {
+	if len(hashes) == 1 {
+		hashes[0].Write(chunk)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, h := range hashes {
+		wg.Add(1)
+		go func(h hash.Hash) {
+			defer wg.Done()
+			h.Write(chunk)
+		}(h)
+	}
+	wg.Wait()
+}
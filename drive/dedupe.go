@@ -0,0 +1,219 @@
+package drive
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+type DuplicateKey int
+
+const (
+	DuplicateByMd5 DuplicateKey = iota
+	DuplicateByName
+)
+
+type DuplicateKeep int
+
+const (
+	KeepNone DuplicateKeep = iota
+	KeepOldest
+	KeepNewest
+)
+
+type DedupeArgs struct {
+	Out         io.Writer
+	Query       string
+	By          DuplicateKey
+	Keep        DuplicateKeep
+	Trash       bool
+	SizeInBytes bool
+}
+
+// Finds files with the same content or the same name in the same
+// directory. When Keep is set all files but the oldest or newest in
+// each group are marked, and moved to the trash if Trash is set. Files
+// the user cannot trash, like files owned by others, are skipped
+func (self *Drive) Dedupe(args DedupeArgs) error {
+	listArgs := listAllFilesArgs{
+		query:   args.Query,
+		fields:  []googleapi.Field{"nextPageToken", "files(id,name,md5Checksum,mimeType,size,createdTime,parents,capabilities(canTrash))"},
+		corpora: "user,allTeamDrives",
+	}
+	files, err := self.listAllFiles(listArgs)
+	if err != nil {
+		return fmt.Errorf("Failed to list files: %s", err)
+	}
+
+	groups := findDuplicates(files, args.By)
+	if len(groups) == 0 {
+		fmt.Fprintln(args.Out, "No duplicates found")
+		return nil
+	}
+
+	pathfinder := self.newPathfinder()
+
+	var trashCount int
+	var trashSize int64
+	var skipCount int
+
+	for i, group := range groups {
+		sort.Sort(byCreatedTime(group))
+
+		keep := keptDuplicate(group, args.Keep)
+
+		fmt.Fprintf(args.Out, "\nGroup %d of %d: %d files\n", i+1, len(groups), len(group))
+
+		w := new(tabwriter.Writer)
+		w.Init(args.Out, 0, 0, 3, ' ', 0)
+
+		for _, f := range group {
+			path, err := pathfinder.absPath(f)
+			if err != nil {
+				return err
+			}
+
+			action := ""
+			if keep != nil {
+				action = "keep"
+				if f != keep {
+					action = "trash"
+					if !canTrash(f) {
+						action = "skip, cannot trash"
+					}
+				}
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				f.Id,
+				path,
+				formatSize(f.Size, args.SizeInBytes),
+				formatDatetime(f.CreatedTime),
+				action,
+			)
+		}
+
+		w.Flush()
+
+		if keep == nil {
+			continue
+		}
+
+		for _, f := range group {
+			if f == keep {
+				continue
+			}
+
+			if !canTrash(f) {
+				skipCount++
+				continue
+			}
+
+			trashCount++
+			trashSize += f.Size
+
+			if !args.Trash {
+				continue
+			}
+
+			if err := self.trashFile(f.Id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if args.Keep == KeepNone {
+		fmt.Fprintf(args.Out, "\nFound %d groups of duplicates\n", len(groups))
+		return nil
+	}
+
+	if args.Trash {
+		fmt.Fprintf(args.Out, "\nTrashed %d files, total %s\n", trashCount, formatSize(trashSize, args.SizeInBytes))
+	} else {
+		fmt.Fprintf(args.Out, "\nWould trash %d files, total %s, use --trash to trash them\n", trashCount, formatSize(trashSize, args.SizeInBytes))
+	}
+	if skipCount > 0 {
+		fmt.Fprintf(args.Out, "Skipped %d files you cannot trash\n", skipCount)
+	}
+	return nil
+}
+
+func findDuplicates(files []*drive.File, by DuplicateKey) [][]*drive.File {
+	var keys []string
+	groups := map[string][]*drive.File{}
+
+	for _, f := range files {
+		var key string
+
+		if by == DuplicateByName {
+			if len(f.Parents) == 0 || isDir(f) {
+				continue
+			}
+			key = f.Parents[0] + "/" + f.Name
+		} else {
+			if !isBinary(f) {
+				continue
+			}
+			key = fmt.Sprintf("%s:%d", f.Md5Checksum, f.Size)
+		}
+
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], f)
+	}
+
+	var duplicates [][]*drive.File
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			duplicates = append(duplicates, groups[key])
+		}
+	}
+
+	return duplicates
+}
+
+// Returns the file to keep in a group sorted by created time
+func keptDuplicate(group []*drive.File, keep DuplicateKeep) *drive.File {
+	switch keep {
+	case KeepOldest:
+		return group[0]
+	case KeepNewest:
+		return group[len(group)-1]
+	}
+	return nil
+}
+
+// Files owned by others can not be trashed outside of team drives
+func canTrash(f *drive.File) bool {
+	return f.Capabilities != nil && f.Capabilities.CanTrash
+}
+
+func (self *Drive) trashFile(id string) error {
+	_, err := self.service.Files.Update(id, &drive.File{Trashed: true}).SupportsTeamDrives(true).Do()
+	if err != nil {
+		return fmt.Errorf("Failed to trash file: %s", err)
+	}
+	return nil
+}
+
+type byCreatedTime []*drive.File
+
+func (self byCreatedTime) Len() int {
+	return len(self)
+}
+
+func (self byCreatedTime) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+}
+
+func (self byCreatedTime) Less(i, j int) bool {
+	ti, _ := time.Parse(time.RFC3339, self[i].CreatedTime)
+	tj, _ := time.Parse(time.RFC3339, self[j].CreatedTime)
+	return ti.Before(tj)
+}
@@ -1,12 +1,15 @@
 package drive
 
 import (
+	"github.com/nanometrics/godrive/cache"
 	"google.golang.org/api/drive/v3"
 	"net/http"
 )
 
 type Drive struct {
 	service *drive.Service
+	client  *http.Client
+	cache   *cache.Cache
 }
 
 func New(client *http.Client) (*Drive, error) {
@@ -15,5 +18,10 @@ func New(client *http.Client) (*Drive, error) {
 		return nil, err
 	}
 
-	return &Drive{service}, nil
+	return &Drive{service: service, client: client}, nil
+}
+
+// Enables caching of sync root listings
+func (self *Drive) SetCache(c *cache.Cache) {
+	self.cache = c
 }
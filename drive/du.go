@@ -0,0 +1,198 @@
+package drive
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+type DiskUsageArgs struct {
+	Out         io.Writer
+	Id          string
+	Depth       int64
+	Top         int64
+	SizeInBytes bool
+}
+
+type usage struct {
+	count int64
+	size  int64
+}
+
+type pathUsage struct {
+	path string
+	usage
+}
+
+// Sums up the size of the tree below a directory. Files are handled one
+// at the time as the tree is walked, only the per folder and per mime
+// type totals and the largest files seen so far are kept in memory
+func (self *Drive) DiskUsage(args DiskUsageArgs) error {
+	if args.Top < 1 {
+		return fmt.Errorf("Number of top entries must be at least 1")
+	}
+
+	root, err := self.service.Files.Get(args.Id).SupportsTeamDrives(true).Fields("id", "name", "mimeType").Do()
+	if err != nil {
+		return fmt.Errorf("Failed to get file: %s", err)
+	}
+
+	if !isDir(root) {
+		return fmt.Errorf("'%s' is not a directory", root.Name)
+	}
+
+	var total usage
+	var dirCount int64
+	folders := map[string]*usage{".": &usage{}}
+	mimes := map[string]*usage{}
+	largest := &fileHeap{}
+
+	err = self.walkFiles(walkFilesArgs{
+		root:   root,
+		fields: []googleapi.Field{"files(id,name,mimeType,size)"},
+		fn: func(f *drive.File, relPath string) error {
+			if isDir(f) {
+				dirCount++
+				if args.Depth == 0 || int64(pathLength(relPath)) < args.Depth {
+					folders[relPath] = &usage{}
+				}
+				return nil
+			}
+
+			total.count++
+			total.size += f.Size
+
+			if _, ok := mimes[f.MimeType]; !ok {
+				mimes[f.MimeType] = &usage{}
+			}
+			mimes[f.MimeType].count++
+			mimes[f.MimeType].size += f.Size
+
+			// Add size to all ancestor folders within depth
+			for dir := filepath.Dir(relPath); ; dir = filepath.Dir(dir) {
+				if u, ok := folders[dir]; ok {
+					u.count++
+					u.size += f.Size
+				}
+				if dir == "." {
+					break
+				}
+			}
+
+			heap.Push(largest, pathUsage{relPath, usage{1, f.Size}})
+			if int64(largest.Len()) > args.Top {
+				heap.Pop(largest)
+			}
+
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(args.Out, "%s: %s in %d files and %d directories\n", root.Name, formatSize(total.size, args.SizeInBytes), total.count, dirCount)
+
+	// Largest folders, root excluded
+	var folderList []pathUsage
+	for path, u := range folders {
+		if path != "." {
+			folderList = append(folderList, pathUsage{path, *u})
+		}
+	}
+	sort.Sort(sort.Reverse(byUsageSize(folderList)))
+	printUsage(args.Out, "\nLargest directories", "Path", truncateUsage(folderList, args.Top), args.SizeInBytes)
+
+	// Largest files, the heap pops smallest first
+	fileList := make([]pathUsage, largest.Len())
+	for i := len(fileList) - 1; i >= 0; i-- {
+		fileList[i] = heap.Pop(largest).(pathUsage)
+	}
+	printUsage(args.Out, "\nLargest files", "Path", fileList, args.SizeInBytes)
+
+	var mimeList []pathUsage
+	for mime, u := range mimes {
+		mimeList = append(mimeList, pathUsage{mime, *u})
+	}
+	sort.Sort(sort.Reverse(byUsageSize(mimeList)))
+	printUsage(args.Out, "\nBy type", "Mime", mimeList, args.SizeInBytes)
+
+	return nil
+}
+
+func truncateUsage(list []pathUsage, n int64) []pathUsage {
+	if int64(len(list)) > n {
+		return list[:n]
+	}
+	return list
+}
+
+func printUsage(out io.Writer, title, column string, list []pathUsage, sizeInBytes bool) {
+	if len(list) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "%s:\n", title)
+
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 0, 3, ' ', 0)
+
+	fmt.Fprintf(w, "%s\tSize\tFiles\n", column)
+
+	for _, pu := range list {
+		fmt.Fprintf(w, "%s\t%s\t%d\n",
+			pu.path,
+			formatSize(pu.size, sizeInBytes),
+			pu.count,
+		)
+	}
+
+	w.Flush()
+}
+
+type byUsageSize []pathUsage
+
+func (self byUsageSize) Len() int {
+	return len(self)
+}
+
+func (self byUsageSize) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+}
+
+func (self byUsageSize) Less(i, j int) bool {
+	return self[i].size < self[j].size
+}
+
+// Min heap used to keep the n largest files
+type fileHeap []pathUsage
+
+func (self fileHeap) Len() int {
+	return len(self)
+}
+
+func (self fileHeap) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+}
+
+func (self fileHeap) Less(i, j int) bool {
+	return self[i].size < self[j].size
+}
+
+func (self *fileHeap) Push(x interface{}) {
+	*self = append(*self, x.(pathUsage))
+}
+
+func (self *fileHeap) Pop() interface{} {
+	old := *self
+	n := len(old)
+	x := old[n-1]
+	*self = old[:n-1]
+	return x
+}
@@ -0,0 +1,258 @@
+package drive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+type HashArgs struct {
+	Out        io.Writer
+	Path       string
+	Id         string
+	Algorithms []HashAlgorithm
+	SkipHeader bool
+}
+
+// Prints the checksums of a local file or directory, a drive file or
+// directory, or both side by side. When both are given, files are matched
+// by their path relative to the given directories. Drive is only used
+// when an id is given, so it may be nil when hashing a local path
+func (self *Drive) Hash(args HashArgs) error {
+	if len(args.Algorithms) == 0 {
+		args.Algorithms = []HashAlgorithm{Md5}
+	}
+	algos := uniqueAlgorithms(args.Algorithms...)
+
+	var local, remote map[string]map[HashAlgorithm]string
+	var localName, remoteName string
+	var err error
+
+	if args.Path != "" {
+		local, localName, err = localChecksums(args.Path, algos)
+		if err != nil {
+			return err
+		}
+	}
+
+	if args.Id != "" {
+		remote, remoteName, err = self.remoteChecksums(args.Id, algos)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Compare single files regardless of name
+	if localName != "" && remoteName != "" {
+		remote = map[string]map[HashAlgorithm]string{localName: remote[remoteName]}
+	}
+
+	compare := local != nil && remote != nil
+
+	var paths []string
+	seen := map[string]bool{}
+	for _, sums := range []map[string]map[HashAlgorithm]string{local, remote} {
+		for path := range sums {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	w := new(tabwriter.Writer)
+	w.Init(args.Out, 0, 0, 3, ' ', 0)
+
+	if !args.SkipHeader {
+		columns := []string{"Path"}
+		for _, algo := range algos {
+			name := strings.ToUpper(algo.String())
+			if local != nil {
+				columns = append(columns, "Local "+name)
+			}
+			if remote != nil {
+				columns = append(columns, "Remote "+name)
+			}
+		}
+		if compare {
+			columns = append(columns, "Match")
+		}
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+	}
+
+	var differ int
+
+	for _, path := range paths {
+		columns := []string{path}
+		for _, algo := range algos {
+			if local != nil {
+				columns = append(columns, local[path][algo])
+			}
+			if remote != nil {
+				columns = append(columns, remote[path][algo])
+			}
+		}
+
+		if compare {
+			match := checksumsMatch(local[path], remote[path], algos)
+			if match != "yes" {
+				differ++
+			}
+			columns = append(columns, match)
+		}
+
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+	}
+
+	w.Flush()
+
+	if differ > 0 {
+		return fmt.Errorf("Found %d files that differ or could not be verified", differ)
+	}
+	return nil
+}
+
+// Returns yes if all checksums are equal, no if any differ, missing if a
+// side is missing, or unverified if drive does not have one of the checksums
+func checksumsMatch(local, remote map[HashAlgorithm]string, algos []HashAlgorithm) string {
+	if local == nil || remote == nil {
+		return "missing"
+	}
+
+	result := "yes"
+	for _, algo := range algos {
+		if remote[algo] == "" {
+			result = "unverified"
+			continue
+		}
+		if local[algo] != remote[algo] {
+			return "no"
+		}
+	}
+
+	return result
+}
+
+// Returns the checksums of a local file, or all files in a local directory
+// keyed by relative path. The name is set when path is a single file.
+// Files are hashed in parallel
+func localChecksums(path string, algos []HashAlgorithm) (map[string]map[HashAlgorithm]string, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to stat file: %s", err)
+	}
+
+	if !info.IsDir() {
+		name := filepath.Base(path)
+		return map[string]map[HashAlgorithm]string{name: Checksums(path, algos)}, name, nil
+	}
+
+	var relPaths []string
+	err = filepath.Walk(path, func(absPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(path, absPath)
+		if err != nil {
+			return err
+		}
+		relPaths = append(relPaths, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to list local files: %s", err)
+	}
+
+	sums := map[string]map[HashAlgorithm]string{}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	paths := make(chan string)
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for relPath := range paths {
+				fileSums := Checksums(filepath.Join(path, relPath), algos)
+
+				mutex.Lock()
+				sums[relPath] = fileSums
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, relPath := range relPaths {
+		paths <- relPath
+	}
+	close(paths)
+	wg.Wait()
+
+	return sums, "", nil
+}
+
+// Returns the checksums drive has for a file, or all files in a directory
+// keyed by relative path. The name is set when id is a single file.
+// Checksums drive does not have are empty
+func (self *Drive) remoteChecksums(id string, algos []HashAlgorithm) (map[string]map[HashAlgorithm]string, string, error) {
+	f, err := self.service.Files.Get(id).SupportsTeamDrives(true).Fields("id", "name", "mimeType").Do()
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to get file: %s", err)
+	}
+
+	var name string
+	relPaths := map[string]string{}
+
+	if isDir(f) {
+		err = self.walkFiles(walkFilesArgs{
+			root:   f,
+			fields: []googleapi.Field{"files(id,name,mimeType)"},
+			fn: func(f *drive.File, relPath string) error {
+				if isBinary(f) {
+					relPaths[f.Id] = relPath
+				}
+				return nil
+			},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		name = f.Name
+		relPaths[f.Id] = f.Name
+	}
+
+	var ids []string
+	for id := range relPaths {
+		ids = append(ids, id)
+	}
+
+	fileSums, err := self.fileChecksums(ids, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sums := map[string]map[HashAlgorithm]string{}
+	for id, relPath := range relPaths {
+		sums[relPath] = map[HashAlgorithm]string{}
+		for _, algo := range algos {
+			sums[relPath][algo] = fileSums[id].get(algo)
+		}
+	}
+
+	return sums, name, nil
+}
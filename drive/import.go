@@ -5,19 +5,45 @@ import (
 	"io"
 	"io/ioutil"
 	"mime"
+	"os"
 	"path/filepath"
 	"strings"
 )
 
 type ImportArgs struct {
-	Out      io.Writer
-	Mime     string
-	Progress io.Writer
-	Path     string
-	Parents  []string
+	Out             io.Writer
+	Mime            string
+	Progress        io.Writer
+	Path            string
+	Parents         []string
+	Recursive       bool
+	SkipUnsupported bool
+	Conversions     map[string]string
+
+	// Number of files skipped in a recursive import
+	// because the requested conversion is not available
+	unconverted *int
 }
 
 func (self *Drive) Import(args ImportArgs) error {
+	if len(args.Parents) == 0 {
+		args.Parents = []string{"root"}
+	}
+
+	about, err := self.service.About.Get().Fields("importFormats").Do()
+	if err != nil {
+		return fmt.Errorf("Failed to get about: %s", err)
+	}
+
+	if args.Recursive {
+		args.unconverted = new(int)
+		err := self.importRecursive(args, about.ImportFormats)
+		if *args.unconverted > 0 {
+			fmt.Fprintf(args.Out, "Skipped %d files that could not be converted as requested\n", *args.unconverted)
+		}
+		return err
+	}
+
 	fromMime := args.Mime
 	if fromMime == "" {
 		fromMime = getMimeType(args.Path)
@@ -26,31 +52,174 @@ func (self *Drive) Import(args ImportArgs) error {
 		return fmt.Errorf("Could not determine mime type of file, use --mime")
 	}
 
-	about, err := self.service.About.Get().Fields("importFormats").Do()
+	toMime, err := importMime(args.Path, fromMime, about.ImportFormats, args.Conversions)
 	if err != nil {
-		return fmt.Errorf("Failed to get about: %s", err)
+		return err
 	}
-
-	toMimes, ok := about.ImportFormats[fromMime]
-	if !ok || len(toMimes) == 0 {
+	if toMime == "" {
 		return fmt.Errorf("Mime type '%s' is not supported for import", fromMime)
 	}
 
-	f, _, err := self.uploadFile(UploadArgs{
+	f, _, _, err := self.uploadFile(UploadArgs{
+		Out:      ioutil.Discard,
+		Progress: args.Progress,
+		Path:     args.Path,
+		Parents:  args.Parents,
+		Mime:     toMime,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(args.Out, "Imported %s with mime type: '%s'\n", f.Id, toMime)
+	return nil
+}
+
+func (self *Drive) importRecursive(args ImportArgs, formats map[string][]string) error {
+	info, err := os.Stat(args.Path)
+	if err != nil {
+		return fmt.Errorf("Failed stat file: %s", err)
+	}
+
+	if info.IsDir() {
+		return self.importDirectory(args, formats)
+	}
+
+	if info.Mode().IsRegular() {
+		return self.importFile(args, formats)
+	}
+
+	return nil
+}
+
+func (self *Drive) importDirectory(args ImportArgs, formats map[string][]string) error {
+	srcFile, srcFileInfo, err := openFile(args.Path)
+	if err != nil {
+		return err
+	}
+
+	// Close file on function exit
+	defer srcFile.Close()
+
+	id, err := self.existingFolderId(args.Parents[0], srcFileInfo.Name())
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		fmt.Fprintf(args.Out, "Creating directory %s\n", srcFileInfo.Name())
+		f, err := self.mkdir(MkdirArgs{
+			Out:     args.Out,
+			Name:    srcFileInfo.Name(),
+			Parents: args.Parents,
+		})
+		if err != nil {
+			return err
+		}
+		id = f.Id
+	} else {
+		fmt.Fprintf(args.Out, "Using existing directory %s (%s)\n", srcFileInfo.Name(), id)
+	}
+
+	names, err := srcFile.Readdirnames(0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("Failed reading directory: %s", err)
+	}
+
+	for _, name := range names {
+		newArgs := args
+		newArgs.Path = filepath.Join(args.Path, name)
+		newArgs.Parents = []string{id}
+
+		err = self.importRecursive(newArgs, formats)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Imports a file of a recursive import, files that can not be
+// converted as requested are skipped instead of failing the import
+func (self *Drive) importFile(args ImportArgs, formats map[string][]string) error {
+	fromMime := args.Mime
+	if fromMime == "" {
+		fromMime = getMimeType(args.Path)
+	}
+
+	toMime, err := importMime(args.Path, fromMime, formats, args.Conversions)
+	if err != nil {
+		fmt.Fprintf(args.Out, "Skipping %s: %s\n", args.Path, err)
+		*args.unconverted++
+		return nil
+	}
+
+	if toMime == "" && args.SkipUnsupported {
+		fmt.Fprintf(args.Out, "Skipping %s, file type is not supported for import\n", args.Path)
+		return nil
+	}
+
+	// Converted documents have no checksum, so a document with the
+	// same name is taken as proof that the file was imported earlier
+	if toMime != "" {
+		existing, err := self.existingFile(args.Parents[0], filepath.Base(args.Path))
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.MimeType == toMime {
+			fmt.Fprintf(args.Out, "Skipping %s, already imported as %s\n", args.Path, existing.Id)
+			return nil
+		}
+	}
+
+	f, _, exists, err := self.uploadFile(UploadArgs{
 		Out:      ioutil.Discard,
 		Progress: args.Progress,
 		Path:     args.Path,
 		Parents:  args.Parents,
-		Mime:     toMimes[0],
+		Mime:     toMime,
 	})
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintf(args.Out, "Imported %s with mime type: '%s'\n", f.Id, toMimes[0])
+	if toMime == "" {
+		if exists {
+			fmt.Fprintf(args.Out, "Skipped %s (%s), already exists\n", args.Path, f.Id)
+		} else {
+			fmt.Fprintf(args.Out, "Uploaded %s as binary %s\n", args.Path, f.Id)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(args.Out, "Imported %s as %s with mime type: '%s'\n", args.Path, f.Id, toMime)
 	return nil
 }
 
+// Returns the mime type the file should be converted to, or an empty
+// string if the file can not be converted. Conversions given by the user
+// are keyed by file extension and take precedence over the default,
+// which is the first format offered by drive
+func importMime(path, fromMime string, formats map[string][]string, conversions map[string]string) (string, error) {
+	toMimes := formats[fromMime]
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if toMime, ok := conversions[ext]; ok {
+		for _, m := range toMimes {
+			if m == toMime {
+				return toMime, nil
+			}
+		}
+		return "", fmt.Errorf("Cannot convert '%s' to '%s', see 'about import' for available conversions", fromMime, toMime)
+	}
+
+	if len(toMimes) == 0 {
+		return "", nil
+	}
+
+	return toMimes[0], nil
+}
+
 func getMimeType(path string) string {
 	t := mime.TypeByExtension(filepath.Ext(path))
 	return strings.Split(t, ";")[0]
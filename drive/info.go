@@ -19,6 +19,15 @@ func (self *Drive) Info(args FileInfoArgs) error {
 		return fmt.Errorf("Failed to get file: %s", err)
 	}
 
+	var sums remoteChecksums
+	if isBinary(f) {
+		checksums, err := self.fileChecksums([]string{f.Id}, 0)
+		if err != nil {
+			return err
+		}
+		sums = checksums[f.Id]
+	}
+
 	pathfinder := self.newPathfinder()
 	absPath, err := pathfinder.absPath(f)
 	if err != nil {
@@ -26,20 +35,24 @@ func (self *Drive) Info(args FileInfoArgs) error {
 	}
 
 	PrintFileInfo(PrintFileInfoArgs{
-		Out:         args.Out,
-		File:        f,
-		Path:        absPath,
-		SizeInBytes: args.SizeInBytes,
+		Out:            args.Out,
+		File:           f,
+		Path:           absPath,
+		Sha1Checksum:   sums.Sha1Checksum,
+		Sha256Checksum: sums.Sha256Checksum,
+		SizeInBytes:    args.SizeInBytes,
 	})
 
 	return nil
 }
 
 type PrintFileInfoArgs struct {
-	Out         io.Writer
-	File        *drive.File
-	Path        string
-	SizeInBytes bool
+	Out            io.Writer
+	File           *drive.File
+	Path           string
+	Sha1Checksum   string
+	Sha256Checksum string
+	SizeInBytes    bool
 }
 
 func PrintFileInfo(args PrintFileInfoArgs) {
@@ -55,6 +68,8 @@ func PrintFileInfo(args PrintFileInfoArgs) {
 		kv{"Created", formatDatetime(f.CreatedTime)},
 		kv{"Modified", formatDatetime(f.ModifiedTime)},
 		kv{"Md5sum", f.Md5Checksum},
+		kv{"Sha1sum", args.Sha1Checksum},
+		kv{"Sha256sum", args.Sha256Checksum},
 		kv{"Shared", formatBool(f.Shared)},
 		kv{"Parents", formatList(f.Parents)},
 		kv{"ViewUrl", f.WebViewLink},
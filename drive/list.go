@@ -61,6 +61,7 @@ type listAllFilesArgs struct {
 	fields    []googleapi.Field
 	sortOrder string
 	maxFiles  int64
+	corpora   string
 }
 
 func (self *Drive) listAllFiles(args listAllFilesArgs) ([]*drive.File, error) {
@@ -75,7 +76,12 @@ func (self *Drive) listAllFiles(args listAllFilesArgs) ([]*drive.File, error) {
 
 	controlledStop := fmt.Errorf("Controlled stop")
 
-	err := self.service.Files.List().SupportsTeamDrives(true).IncludeTeamDriveItems(true).Q(args.query).Fields(args.fields...).OrderBy(args.sortOrder).PageSize(pageSize).Pages(context.TODO(), func(fl *drive.FileList) error {
+	call := self.service.Files.List().SupportsTeamDrives(true).IncludeTeamDriveItems(true).Q(args.query).Fields(args.fields...).OrderBy(args.sortOrder).PageSize(pageSize)
+	if args.corpora != "" {
+		call = call.Corpora(args.corpora)
+	}
+
+	err := call.Pages(context.TODO(), func(fl *drive.FileList) error {
 		files = append(files, fl.Files...)
 
 		// Stop when we have all the files we need
@@ -0,0 +1,121 @@
+package drive
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// AppProperties used by the sync commands to tag files
+var SyncAppProperties = []string{"sync", "syncRoot", "syncRootId"}
+
+type GetMetaArgs struct {
+	Out io.Writer
+	Id  string
+}
+
+func (self *Drive) GetMeta(args GetMetaArgs) error {
+	f, err := self.service.Files.Get(args.Id).SupportsTeamDrives(true).Fields("id", "name", "mimeType", "description", "starred", "properties", "appProperties").Do()
+	if err != nil {
+		return fmt.Errorf("Failed to get file: %s", err)
+	}
+
+	items := []kv{
+		kv{"Id", f.Id},
+		kv{"Name", f.Name},
+		kv{"Mime", f.MimeType},
+		kv{"Description", f.Description},
+		kv{"Starred", formatBool(f.Starred)},
+	}
+
+	for _, item := range items {
+		if item.value != "" {
+			fmt.Fprintf(args.Out, "%s: %s\n", item.key, item.value)
+		}
+	}
+
+	printProperties(args.Out, "Properties", f.Properties)
+	printProperties(args.Out, "AppProperties", f.AppProperties)
+	return nil
+}
+
+type SetMetaArgs struct {
+	Out           io.Writer
+	Id            string
+	Description   string
+	Mime          string
+	Properties    map[string]string
+	AppProperties map[string]string
+	Star          bool
+	Unstar        bool
+}
+
+func (self *Drive) SetMeta(args SetMetaArgs) error {
+	if args.Star && args.Unstar {
+		return fmt.Errorf("Only one of star and unstar can be given")
+	}
+
+	for _, key := range SyncAppProperties {
+		if _, ok := args.AppProperties[key]; ok {
+			return fmt.Errorf("AppProperty '%s' is managed by the sync commands and can't be set", key)
+		}
+	}
+
+	patch := map[string]interface{}{}
+
+	if args.Description != "" {
+		patch["description"] = args.Description
+	}
+
+	if args.Mime != "" {
+		patch["mimeType"] = args.Mime
+	}
+
+	if args.Star || args.Unstar {
+		patch["starred"] = args.Star
+	}
+
+	if len(args.Properties) > 0 {
+		patch["properties"] = propertiesPatch(args.Properties)
+	}
+
+	if len(args.AppProperties) > 0 {
+		patch["appProperties"] = propertiesPatch(args.AppProperties)
+	}
+
+	if len(patch) == 0 {
+		return fmt.Errorf("Nothing to update")
+	}
+
+	f, err := self.patchFile(args.Id, patch, "id", "name")
+	if err != nil {
+		return fmt.Errorf("Failed to update file: %s", err)
+	}
+
+	fmt.Fprintf(args.Out, "Updated metadata of %s (%s)\n", f.Name, f.Id)
+	return nil
+}
+
+func printProperties(out io.Writer, title string, properties map[string]string) {
+	if len(properties) == 0 {
+		return
+	}
+
+	var keys []string
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(out, "%s:\n", title)
+
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 0, 3, ' ', 0)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s\t%s\n", key, properties[key])
+	}
+
+	w.Flush()
+}
@@ -16,6 +16,7 @@ type MkdirArgs struct {
 	Name        string
 	Description string
 	Parents     []string
+	Properties  map[string]string
 }
 
 func (self *Drive) Mkdir(args MkdirArgs) error {
@@ -32,6 +33,7 @@ func (self *Drive) mkdir(args MkdirArgs) (*drive.File, error) {
 		Name:        args.Name,
 		Description: args.Description,
 		MimeType:    DirectoryMimeType,
+		Properties:  args.Properties,
 	}
 	dstFile.Parents = args.Parents
 	var f *drive.File
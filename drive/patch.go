@@ -0,0 +1,70 @@
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// Patches file metadata with a raw json body. Unlike Files.Update this
+// can remove single properties and appProperties, which drive requires
+// to be sent as null values
+func (self *Drive) patchFile(id string, patch map[string]interface{}, fields ...string) (*drive.File, error) {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("supportsTeamDrives", "true")
+	if len(fields) > 0 {
+		params.Set("fields", strings.Join(fields, ","))
+	}
+
+	urls := googleapi.ResolveRelative(self.service.BasePath, "files/"+url.PathEscape(id)) + "?" + params.Encode()
+
+	req, err := http.NewRequest("PATCH", urls, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := self.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	f := &drive.File{}
+	if err := json.NewDecoder(res.Body).Decode(f); err != nil {
+		return nil, fmt.Errorf("Failed to decode file: %s", err)
+	}
+
+	return f, nil
+}
+
+// Converts a property map to a patch value where
+// empty values are sent as null, which removes them
+func propertiesPatch(properties map[string]string) map[string]interface{} {
+	patch := map[string]interface{}{}
+
+	for key, value := range properties {
+		if value == "" {
+			patch[key] = nil
+		} else {
+			patch[key] = value
+		}
+	}
+
+	return patch
+}
@@ -20,9 +20,7 @@ type DownloadRevisionArgs struct {
 }
 
 func (self *Drive) DownloadRevision(args DownloadRevisionArgs) (err error) {
-	getRev := self.service.Revisions.Get(args.FileId, args.RevisionId)
-
-	rev, err := getRev.Fields("originalFilename").Do()
+	rev, err := self.service.Revisions.Get(args.FileId, args.RevisionId).Fields("originalFilename").Do()
 	if err != nil {
 		return fmt.Errorf("Failed to get file: %s", err)
 	}
@@ -31,20 +29,6 @@ func (self *Drive) DownloadRevision(args DownloadRevisionArgs) (err error) {
 		return fmt.Errorf("Download is not supported for this file type")
 	}
 
-	// Get timeout reader wrapper and context
-	timeoutReaderWrapper, ctx := getTimeoutReaderWrapperContext(args.Timeout)
-
-	res, err := getRev.Context(ctx).Download()
-	if err != nil {
-		if isTimeoutError(err) {
-			return fmt.Errorf("Failed to download file: timeout, no data was transferred for %v", args.Timeout)
-		}
-		return fmt.Errorf("Failed to download file: %s", err)
-	}
-
-	// Close body on function exit
-	defer res.Body.Close()
-
 	// Discard other output if file is written to stdout
 	out := args.Out
 	if args.Stdout {
@@ -56,14 +40,15 @@ func (self *Drive) DownloadRevision(args DownloadRevisionArgs) (err error) {
 
 	fmt.Fprintf(out, "Downloading %s -> %s\n", rev.OriginalFilename, fpath)
 
-	bytes, rate, err := self.saveFile(saveFileArgs{
-		out:           args.Out,
-		body:          timeoutReaderWrapper(res.Body),
-		contentLength: res.ContentLength,
-		fpath:         fpath,
-		force:         args.Force,
-		stdout:        args.Stdout,
-		progress:      args.Progress,
+	bytes, rate, err := self.saveRevision(saveRevisionArgs{
+		out:        args.Out,
+		progress:   args.Progress,
+		fileId:     args.FileId,
+		revisionId: args.RevisionId,
+		fpath:      fpath,
+		force:      args.Force,
+		stdout:     args.Stdout,
+		timeout:    args.Timeout,
 	})
 
 	if err != nil {
@@ -73,3 +58,40 @@ func (self *Drive) DownloadRevision(args DownloadRevisionArgs) (err error) {
 	fmt.Fprintf(out, "Download complete, rate: %s/s, total size: %s\n", formatSize(rate, false), formatSize(bytes, false))
 	return nil
 }
+
+type saveRevisionArgs struct {
+	out        io.Writer
+	progress   io.Writer
+	fileId     string
+	revisionId string
+	fpath      string
+	force      bool
+	stdout     bool
+	timeout    time.Duration
+}
+
+func (self *Drive) saveRevision(args saveRevisionArgs) (int64, int64, error) {
+	// Get timeout reader wrapper and context
+	timeoutReaderWrapper, ctx := getTimeoutReaderWrapperContext(args.timeout)
+
+	res, err := self.service.Revisions.Get(args.fileId, args.revisionId).Context(ctx).Download()
+	if err != nil {
+		if isTimeoutError(err) {
+			return 0, 0, fmt.Errorf("Failed to download file: timeout, no data was transferred for %v", args.timeout)
+		}
+		return 0, 0, fmt.Errorf("Failed to download file: %s", err)
+	}
+
+	// Close body on function exit
+	defer res.Body.Close()
+
+	return self.saveFile(saveFileArgs{
+		out:           args.out,
+		body:          timeoutReaderWrapper(res.Body),
+		contentLength: res.ContentLength,
+		fpath:         args.fpath,
+		force:         args.force,
+		stdout:        args.stdout,
+		progress:      args.progress,
+	})
+}
@@ -0,0 +1,50 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io"
+)
+
+type KeepRevisionArgs struct {
+	Out        io.Writer
+	FileId     string
+	RevisionId string
+	Unset      bool
+	Recursive  bool
+}
+
+func (self *Drive) KeepRevision(args KeepRevisionArgs) error {
+	if err := checkRecursiveRevisionId(args.RevisionId, args.Recursive); err != nil {
+		return err
+	}
+
+	return self.forEachRevisionFile(args.FileId, args.Recursive, func(f *drive.File, path string) error {
+		revisions, err := self.listAllRevisions(f.Id)
+		if err != nil {
+			return err
+		}
+
+		revId, err := resolveRevisionId(args.RevisionId, revisions)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+
+		rev := &drive.Revision{
+			KeepForever:     !args.Unset,
+			ForceSendFields: []string{"KeepForever"},
+		}
+
+		_, err = self.service.Revisions.Update(f.Id, revId, rev).Fields("id", "keepForever").Do()
+		if err != nil {
+			return fmt.Errorf("Failed to update revision '%s' of %s: %s", revId, path, err)
+		}
+
+		if args.Unset {
+			fmt.Fprintf(args.Out, "Revision '%s' of %s is no longer kept forever\n", revId, path)
+		} else {
+			fmt.Fprintf(args.Out, "Revision '%s' of %s is kept forever\n", revId, path)
+		}
+		return nil
+	})
+}
@@ -2,8 +2,11 @@ package drive
 
 import (
 	"fmt"
+	"golang.org/x/net/context"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"io"
+	"path/filepath"
 	"text/tabwriter"
 )
 
@@ -60,3 +63,88 @@ func PrintRevisionList(args PrintRevisionListArgs) {
 
 	w.Flush()
 }
+
+func (self *Drive) listAllRevisions(fileId string) ([]*drive.Revision, error) {
+	var revisions []*drive.Revision
+
+	err := self.service.Revisions.List(fileId).Fields("nextPageToken", "revisions(id,keepForever,size,modifiedTime,originalFilename,md5Checksum)").Pages(context.TODO(), func(rl *drive.RevisionList) error {
+		revisions = append(revisions, rl.Revisions...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed listing revisions: %s", err)
+	}
+
+	return revisions, nil
+}
+
+// Resolves the revision keywords 'head' and 'previous' against the
+// revisions of a file, which are listed oldest first. Other ids are
+// returned as is
+func resolveRevisionId(revId string, revisions []*drive.Revision) (string, error) {
+	n := len(revisions)
+
+	switch revId {
+	case "head":
+		if n < 1 {
+			return "", fmt.Errorf("File has no revisions")
+		}
+		return revisions[n-1].Id, nil
+	case "previous":
+		if n < 2 {
+			return "", fmt.Errorf("File has no previous revision")
+		}
+		return revisions[n-2].Id, nil
+	}
+
+	return revId, nil
+}
+
+// Revision ids are only valid for the file they belong to, so a
+// directory can only be handled with the 'head' and 'previous' keywords
+func checkRecursiveRevisionId(revId string, recursive bool) error {
+	if recursive && revId != "head" && revId != "previous" {
+		return fmt.Errorf("Revision '%s' belongs to a single file, use 'head' or 'previous' with --recursive", revId)
+	}
+	return nil
+}
+
+// Returns the revision with the given id
+func findRevision(revId string, revisions []*drive.Revision) (*drive.Revision, error) {
+	for _, rev := range revisions {
+		if rev.Id == revId {
+			return rev, nil
+		}
+	}
+	return nil, fmt.Errorf("Revision '%s' not found", revId)
+}
+
+type revisionFileFunc func(f *drive.File, path string) error
+
+// Calls fn with the given file, or with every binary file below
+// it when it is a directory and recursive is set
+func (self *Drive) forEachRevisionFile(id string, recursive bool, fn revisionFileFunc) error {
+	f, err := self.service.Files.Get(id).SupportsTeamDrives(true).Fields("id", "name", "mimeType", "md5Checksum").Do()
+	if err != nil {
+		return fmt.Errorf("Failed to get file: %s", err)
+	}
+
+	if !isDir(f) {
+		return fn(f, f.Name)
+	}
+
+	if !recursive {
+		return fmt.Errorf("'%s' is a directory, use --recursive to handle all files in it", f.Name)
+	}
+
+	return self.walkFiles(walkFilesArgs{
+		root:   f,
+		fields: []googleapi.Field{"files(id,name,mimeType,md5Checksum)"},
+		fn: func(child *drive.File, relPath string) error {
+			if !isBinary(child) {
+				return nil
+			}
+			return fn(child, filepath.Join(f.Name, relPath))
+		},
+	})
+}
@@ -0,0 +1,94 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"time"
+)
+
+type PruneRevisionsArgs struct {
+	Out         io.Writer
+	FileId      string
+	KeepLast    int64
+	OlderThan   time.Duration
+	Recursive   bool
+	DryRun      bool
+	SizeInBytes bool
+}
+
+// Deletes old revisions. The newest KeepLast revisions, revisions newer
+// than OlderThan and revisions marked keep forever are never deleted
+func (self *Drive) PruneRevisions(args PruneRevisionsArgs) error {
+	if args.KeepLast < 1 {
+		return fmt.Errorf("At least one revision must be kept")
+	}
+
+	// Without an age every revision but the last ones would be deleted
+	if args.OlderThan <= 0 {
+		return fmt.Errorf("An age is required, use --older-than to only delete revisions older than it")
+	}
+
+	cutoff := time.Now().Add(-args.OlderThan)
+
+	var count int
+	var size int64
+
+	err := self.forEachRevisionFile(args.FileId, args.Recursive, func(f *drive.File, path string) error {
+		revisions, err := self.listAllRevisions(f.Id)
+		if err != nil {
+			return err
+		}
+
+		for _, rev := range pruneCandidates(revisions, args.KeepLast, cutoff) {
+			fmt.Fprintf(args.Out, "Deleting revision '%s' of %s (%s, %s)\n", rev.Id, path, formatDatetime(rev.ModifiedTime), formatSize(rev.Size, args.SizeInBytes))
+			count++
+			size += rev.Size
+
+			if args.DryRun {
+				continue
+			}
+
+			err = self.service.Revisions.Delete(f.Id, rev.Id).Do()
+			if err != nil {
+				return fmt.Errorf("Failed to delete revision '%s' of %s: %s", rev.Id, path, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if args.DryRun {
+		fmt.Fprintf(args.Out, "Would delete %d revisions, total %s\n", count, formatSize(size, args.SizeInBytes))
+	} else {
+		fmt.Fprintf(args.Out, "Deleted %d revisions, total %s\n", count, formatSize(size, args.SizeInBytes))
+	}
+	return nil
+}
+
+func pruneCandidates(revisions []*drive.Revision, keepLast int64, cutoff time.Time) []*drive.Revision {
+	var candidates []*drive.Revision
+
+	// Revisions are listed oldest first, the last ones are always kept
+	n := len(revisions) - int(keepLast)
+
+	for i := 0; i < n; i++ {
+		rev := revisions[i]
+
+		if rev.KeepForever {
+			continue
+		}
+
+		modified, err := time.Parse(time.RFC3339, rev.ModifiedTime)
+		if err != nil || !modified.Before(cutoff) {
+			continue
+		}
+
+		candidates = append(candidates, rev)
+	}
+
+	return candidates
+}
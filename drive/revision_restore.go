@@ -0,0 +1,111 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+	"time"
+)
+
+type RestoreRevisionArgs struct {
+	Out        io.Writer
+	Progress   io.Writer
+	FileId     string
+	RevisionId string
+	Recursive  bool
+	ChunkSize  int64
+	Timeout    time.Duration
+}
+
+// Restores an old revision by uploading its content as a new head
+// revision, the revisions in between are left untouched. The md5 of the
+// new head revision must match the md5 of the restored revision
+func (self *Drive) RestoreRevision(args RestoreRevisionArgs) error {
+	if args.ChunkSize > intMax()-1 {
+		return fmt.Errorf("Chunk size is to big, max chunk size for this computer is %d", intMax()-1)
+	}
+
+	if err := checkRecursiveRevisionId(args.RevisionId, args.Recursive); err != nil {
+		return err
+	}
+
+	return self.forEachRevisionFile(args.FileId, args.Recursive, func(f *drive.File, path string) error {
+		revisions, err := self.listAllRevisions(f.Id)
+		if err != nil {
+			return err
+		}
+
+		if len(revisions) == 0 {
+			return fmt.Errorf("%s: File has no revisions", path)
+		}
+
+		revId, err := resolveRevisionId(args.RevisionId, revisions)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+
+		rev, err := findRevision(revId, revisions)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+
+		if rev == revisions[len(revisions)-1] {
+			fmt.Fprintf(args.Out, "Skipping %s, revision '%s' is already the head revision\n", path, revId)
+			return nil
+		}
+
+		if rev.Md5Checksum == "" {
+			return fmt.Errorf("%s: Revision '%s' has no md5 checksum, the restored content can not be verified", path, revId)
+		}
+
+		fmt.Fprintf(args.Out, "Restoring revision '%s' of %s\n", revId, path)
+		started := time.Now()
+
+		updated, err := self.restoreRevision(f.Id, revId, args)
+		if err != nil {
+			return err
+		}
+
+		if updated.Md5Checksum != rev.Md5Checksum {
+			return fmt.Errorf("%s: md5 of the new head revision %s does not match revision '%s' (%s)", path, updated.Md5Checksum, revId, rev.Md5Checksum)
+		}
+
+		rate := calcRate(updated.Size, started, time.Now())
+		fmt.Fprintf(args.Out, "Restored %s at %s/s, total %s\n", updated.Id, formatSize(rate, false), formatSize(updated.Size, false))
+		return nil
+	})
+}
+
+func (self *Drive) restoreRevision(fileId, revId string, args RestoreRevisionArgs) (*drive.File, error) {
+	// Get timeout reader wrapper and context
+	timeoutReaderWrapper, ctx := getTimeoutReaderWrapperContext(args.Timeout)
+
+	res, err := self.service.Revisions.Get(fileId, revId).Context(ctx).Download()
+	if err != nil {
+		if isTimeoutError(err) {
+			return nil, fmt.Errorf("Failed to download revision: timeout, no data was transferred for %v", args.Timeout)
+		}
+		return nil, fmt.Errorf("Failed to download revision: %s", err)
+	}
+
+	// Close body on function exit
+	defer res.Body.Close()
+
+	// Chunk size option
+	chunkSize := googleapi.ChunkSize(int(args.ChunkSize))
+
+	// Stream the revision straight back to drive
+	progressReader := getProgressReader(res.Body, args.Progress, res.ContentLength)
+	reader := timeoutReaderWrapper(progressReader)
+
+	f, err := self.service.Files.Update(fileId, &drive.File{}).SupportsTeamDrives(true).Fields("id", "name", "size", "md5Checksum").Context(ctx).Media(reader, chunkSize).Do()
+	if err != nil {
+		if isTimeoutError(err) {
+			return nil, fmt.Errorf("Failed to restore revision: timeout, no data was transferred for %v", args.Timeout)
+		}
+		return nil, fmt.Errorf("Failed to restore revision: %s", err)
+	}
+
+	return f, nil
+}
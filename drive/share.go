@@ -5,16 +5,24 @@ import (
 	"google.golang.org/api/drive/v3"
 	"io"
 	"text/tabwriter"
+	"time"
 )
 
+// Drive limits how far ahead a permission can expire
+const MaxPermissionExpiration = 365 * 24 * time.Hour
+
 type ShareArgs struct {
-	Out          io.Writer
-	FileId       string
-	Role         string
-	Type         string
-	Email        string
-	Domain       string
-	Discoverable bool
+	Out               io.Writer
+	FileId            string
+	Role              string
+	Type              string
+	Email             string
+	Domain            string
+	Discoverable      bool
+	Expiration        time.Time
+	NoNotify          bool
+	Message           string
+	TransferOwnership bool
 }
 
 func (self *Drive) Share(args ShareArgs) error {
@@ -26,7 +34,34 @@ func (self *Drive) Share(args ShareArgs) error {
 		Domain:             args.Domain,
 	}
 
-	_, err := self.service.Permissions.Create(args.FileId, permission).Do()
+	if !args.Expiration.IsZero() {
+		permission.ExpirationTime = args.Expiration.UTC().Format(time.RFC3339)
+	}
+
+	err := validatePermission(permission, permissionOptions{
+		transferOwnership: args.TransferOwnership,
+		notify:            !args.NoNotify,
+		message:           args.Message,
+	})
+	if err != nil {
+		return err
+	}
+
+	call := self.service.Permissions.Create(args.FileId, permission).SupportsTeamDrives(true)
+
+	// Drive only sends notifications to users and groups
+	if isUserOrGroup(args.Type) {
+		call = call.SendNotificationEmail(!args.NoNotify)
+		if args.Message != "" {
+			call = call.EmailMessage(args.Message)
+		}
+	}
+
+	if args.TransferOwnership {
+		call = call.TransferOwnership(true)
+	}
+
+	_, err = call.Do()
 	if err != nil {
 		return fmt.Errorf("Failed to share file: %s", err)
 	}
@@ -35,6 +70,165 @@ func (self *Drive) Share(args ShareArgs) error {
 	return nil
 }
 
+type UpdatePermissionArgs struct {
+	Out               io.Writer
+	FileId            string
+	PermissionId      string
+	Role              string
+	Expiration        time.Time
+	RemoveExpiration  bool
+	TransferOwnership bool
+}
+
+// Changes the role or expiration of an existing permission
+func (self *Drive) UpdatePermission(args UpdatePermissionArgs) error {
+	if args.Role == "" && args.Expiration.IsZero() && !args.RemoveExpiration {
+		return fmt.Errorf("Nothing to update, give a role, an expiration or remove the expiration")
+	}
+
+	if !args.Expiration.IsZero() && args.RemoveExpiration {
+		return fmt.Errorf("Expiration can not be both set and removed")
+	}
+
+	current, err := self.service.Permissions.Get(args.FileId, args.PermissionId).SupportsTeamDrives(true).Fields("id", "type", "role", "emailAddress", "domain", "expirationTime").Do()
+	if err != nil {
+		return fmt.Errorf("Failed to get permission: %s", err)
+	}
+
+	if current.Role == "owner" {
+		return fmt.Errorf("The owner permission can not be changed, transfer ownership to another user instead")
+	}
+
+	// Validate the permission as it will be after the update
+	updated := *current
+	patch := &drive.Permission{}
+	if args.Role != "" {
+		updated.Role = args.Role
+		patch.Role = args.Role
+	}
+	if !args.Expiration.IsZero() {
+		updated.ExpirationTime = args.Expiration.UTC().Format(time.RFC3339)
+		patch.ExpirationTime = updated.ExpirationTime
+	}
+	if args.RemoveExpiration {
+		updated.ExpirationTime = ""
+	}
+
+	err = validatePermission(&updated, permissionOptions{transferOwnership: args.TransferOwnership, notify: true})
+	if err != nil {
+		return err
+	}
+
+	call := self.service.Permissions.Update(args.FileId, args.PermissionId, patch).SupportsTeamDrives(true)
+	if args.RemoveExpiration {
+		call = call.RemoveExpiration(true)
+	}
+	if args.TransferOwnership {
+		call = call.TransferOwnership(true)
+	}
+
+	p, err := call.Fields("id", "role", "expirationTime").Do()
+	if err != nil {
+		return fmt.Errorf("Failed to update permission: %s", err)
+	}
+
+	if p.ExpirationTime != "" {
+		fmt.Fprintf(args.Out, "Permission is now %s, expires %s\n", p.Role, formatDatetime(p.ExpirationTime))
+	} else {
+		fmt.Fprintf(args.Out, "Permission is now %s\n", p.Role)
+	}
+	return nil
+}
+
+type permissionOptions struct {
+	transferOwnership bool
+	notify            bool
+	message           string
+}
+
+// Checks the combination of type, role and options before sending it, drive
+// gives unclear errors for many of the combinations it does not accept
+func validatePermission(p *drive.Permission, opts permissionOptions) error {
+	switch p.Type {
+	case "user", "group":
+		if p.EmailAddress == "" {
+			return fmt.Errorf("Type %s requires an email", p.Type)
+		}
+		if p.Domain != "" {
+			return fmt.Errorf("Domain can only be given with type domain")
+		}
+	case "domain":
+		if p.Domain == "" {
+			return fmt.Errorf("Type domain requires a domain")
+		}
+	case "anyone":
+		if p.Domain != "" {
+			return fmt.Errorf("Domain can only be given with type domain")
+		}
+	default:
+		return fmt.Errorf("Unknown type '%s', must be user, group, domain or anyone", p.Type)
+	}
+
+	if !isUserOrGroup(p.Type) && p.EmailAddress != "" {
+		return fmt.Errorf("Email can only be given with type user or group")
+	}
+
+	switch p.Role {
+	case "owner":
+		if p.Type != "user" {
+			return fmt.Errorf("Only a user can be owner")
+		}
+		if !opts.transferOwnership {
+			return fmt.Errorf("Role owner transfers the ownership of the file, this must be confirmed with transfer ownership")
+		}
+		if !opts.notify {
+			return fmt.Errorf("Drive always notifies the new owner, notifications can not be disabled when transferring ownership")
+		}
+	case "organizer", "fileOrganizer":
+		if !isUserOrGroup(p.Type) {
+			return fmt.Errorf("Role %s can only be given to a user or group", p.Role)
+		}
+	case "writer", "commenter", "reader":
+	default:
+		return fmt.Errorf("Unknown role '%s', must be owner, organizer, fileOrganizer, writer, commenter or reader", p.Role)
+	}
+
+	if opts.transferOwnership && p.Role != "owner" {
+		return fmt.Errorf("Transferring ownership requires role owner")
+	}
+
+	if p.AllowFileDiscovery && p.Type != "domain" && p.Type != "anyone" {
+		return fmt.Errorf("Only domain and anyone permissions can be discoverable")
+	}
+
+	if p.ExpirationTime != "" {
+		if !isUserOrGroup(p.Type) || p.Role == "owner" || p.Role == "organizer" || p.Role == "fileOrganizer" {
+			return fmt.Errorf("Expiration can only be set on writer, commenter and reader permissions of users and groups")
+		}
+
+		expiration, err := time.Parse(time.RFC3339, p.ExpirationTime)
+		if err != nil {
+			return fmt.Errorf("Invalid expiration '%s': %s", p.ExpirationTime, err)
+		}
+		if !expiration.After(time.Now()) {
+			return fmt.Errorf("Expiration must be in the future")
+		}
+		if expiration.After(time.Now().Add(MaxPermissionExpiration)) {
+			return fmt.Errorf("Expiration can not be more than a year in the future")
+		}
+	}
+
+	if opts.message != "" && (!opts.notify || !isUserOrGroup(p.Type)) {
+		return fmt.Errorf("A message can only be sent with the notification to a user or group")
+	}
+
+	return nil
+}
+
+func isUserOrGroup(permissionType string) bool {
+	return permissionType == "user" || permissionType == "group"
+}
+
 type RevokePermissionArgs struct {
 	Out          io.Writer
 	FileId       string
@@ -0,0 +1,154 @@
+package drive
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const permissionFields = "permissions(id,type,role,emailAddress,domain,allowFileDiscovery)"
+
+// Selects permissions, empty fields match any value
+type PermissionFilter struct {
+	Type   string
+	Role   string
+	Email  string
+	Domain string
+}
+
+func (self PermissionFilter) IsEmpty() bool {
+	return self.Type == "" && self.Role == "" && self.Email == "" && self.Domain == ""
+}
+
+func (self PermissionFilter) matches(p *drive.Permission) bool {
+	return (self.Type == "" || self.Type == p.Type) &&
+		(self.Role == "" || self.Role == p.Role) &&
+		(self.Email == "" || strings.EqualFold(self.Email, p.EmailAddress)) &&
+		(self.Domain == "" || strings.EqualFold(self.Domain, p.Domain))
+}
+
+type ShareAuditArgs struct {
+	Out        io.Writer
+	Id         string
+	Recursive  bool
+	Filter     PermissionFilter
+	SkipHeader bool
+}
+
+// Lists the permissions of a file, or of a directory and the files in
+// it, with the full path of each file. Only the direct children of a
+// directory are checked unless recursive is set
+func (self *Drive) ShareAudit(args ShareAuditArgs) error {
+	w := new(tabwriter.Writer)
+	w.Init(args.Out, 0, 0, 3, ' ', 0)
+
+	if !args.SkipHeader {
+		fmt.Fprintln(w, "Path\tFileId\tPermissionId\tType\tRole\tEmail\tDomain\tAnyone with link\tDiscoverable")
+	}
+
+	err := self.forEachShareFile(args.Id, args.Recursive, func(f *drive.File, path string) error {
+		permissions, err := self.filePermissions(f)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range permissions {
+			if !args.Filter.matches(p) {
+				continue
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				path,
+				f.Id,
+				p.Id,
+				p.Type,
+				p.Role,
+				p.EmailAddress,
+				p.Domain,
+				formatBool(p.Type == "anyone"),
+				formatBool(p.AllowFileDiscovery),
+			)
+		}
+		return nil
+	})
+
+	w.Flush()
+	return err
+}
+
+type shareFileFunc func(f *drive.File, path string) error
+
+// Calls fn for the file and, if it is a directory, the files in it
+// with the full path of each file and the permissions when drive has them
+func (self *Drive) forEachShareFile(id string, recursive bool, fn shareFileFunc) error {
+	root, err := self.service.Files.Get(id).SupportsTeamDrives(true).Fields("id", "name", "mimeType", "parents", permissionFields).Do()
+	if err != nil {
+		return fmt.Errorf("Failed to get file: %s", err)
+	}
+
+	rootPath, err := self.newPathfinder().absPath(root)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(root, rootPath); err != nil {
+		return err
+	}
+
+	if !isDir(root) {
+		return nil
+	}
+
+	fields := []googleapi.Field{googleapi.Field(fmt.Sprintf("files(id,name,mimeType,%s)", permissionFields))}
+
+	if recursive {
+		return self.walkFiles(walkFilesArgs{
+			root:   root,
+			fields: fields,
+			fn: func(f *drive.File, relPath string) error {
+				return fn(f, filepath.Join(rootPath, relPath))
+			},
+		})
+	}
+
+	files, err := self.listAllFiles(listAllFilesArgs{
+		query:  fmt.Sprintf("'%s' in parents and trashed = false", root.Id),
+		fields: append([]googleapi.Field{"nextPageToken"}, fields...),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed listing files: %s", err)
+	}
+
+	for _, f := range files {
+		if err := fn(f, filepath.Join(rootPath, f.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drive does not include permissions when listing files of shared drives,
+// they are listed separately for files without them
+func (self *Drive) filePermissions(f *drive.File) ([]*drive.Permission, error) {
+	if len(f.Permissions) > 0 {
+		return f.Permissions, nil
+	}
+
+	var permissions []*drive.Permission
+	call := self.service.Permissions.List(f.Id).SupportsTeamDrives(true).Fields("nextPageToken", permissionFields)
+	err := call.Pages(context.TODO(), func(pl *drive.PermissionList) error {
+		permissions = append(permissions, pl.Permissions...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list permissions of '%s': %s", f.Name, err)
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,304 @@
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// Default max number of permission changes per second, drive rejects
+// sharing changes that are made too fast with rate limit errors
+const DefaultShareRate = 10
+
+type RevokePermissionsArgs struct {
+	Out       io.Writer
+	Id        string
+	Filter    PermissionFilter
+	Recursive bool
+	DryRun    bool
+	Rate      int64
+}
+
+// Revokes the permissions matching the filter from a file or the files in a
+// directory. Owner permissions are skipped. The changes are sent in batches
+func (self *Drive) RevokePermissions(args RevokePermissionsArgs) error {
+	if args.Filter.IsEmpty() {
+		return fmt.Errorf("At least one of type, role, email or domain is required to select the permissions to revoke")
+	}
+
+	batcher := newPermissionBatcher(self, args.Out, args.Rate)
+	count := 0
+
+	err := self.forEachShareFile(args.Id, args.Recursive, func(f *drive.File, path string) error {
+		permissions, err := self.filePermissions(f)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range permissions {
+			if !args.Filter.matches(p) || p.Role == "owner" {
+				continue
+			}
+
+			desc := fmt.Sprintf("%s permission of %s on %s", p.Role, describeGrantee(p.Type, p.EmailAddress, p.Domain), path)
+			fmt.Fprintf(args.Out, "Revoking %s\n", desc)
+			count++
+
+			if args.DryRun {
+				continue
+			}
+
+			// Permissions inherited from a revoked parent may already be gone
+			err := batcher.add(permissionChange{
+				desc:           desc,
+				ignoreNotFound: true,
+				request: batchRequest{
+					method: "DELETE",
+					path:   fmt.Sprintf("/drive/v3/files/%s/permissions/%s?supportsTeamDrives=true", url.PathEscape(f.Id), url.PathEscape(p.Id)),
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if flushErr := batcher.flush(); err == nil {
+		err = flushErr
+	}
+
+	if args.DryRun {
+		fmt.Fprintf(args.Out, "Would revoke %d permissions\n", count)
+	} else {
+		fmt.Fprintf(args.Out, "Revoked %d permissions\n", batcher.done)
+	}
+
+	if err != nil {
+		return err
+	}
+	if batcher.failed > 0 {
+		return fmt.Errorf("Failed to revoke %d permissions", batcher.failed)
+	}
+	return nil
+}
+
+type GrantPermissionsArgs struct {
+	Out          io.Writer
+	Id           string
+	Role         string
+	Type         string
+	Email        string
+	Domain       string
+	Discoverable bool
+	Expiration   time.Time
+	NoNotify     bool
+	Message      string
+	Recursive    bool
+	DryRun       bool
+	Rate         int64
+}
+
+// Grants a permission on a file or the files in a directory, files that
+// already have a permission for the grantee are skipped, with a note when
+// its role differs. The changes are sent in batches
+func (self *Drive) GrantPermissions(args GrantPermissionsArgs) error {
+	permission := &drive.Permission{
+		Role:               args.Role,
+		Type:               args.Type,
+		EmailAddress:       args.Email,
+		Domain:             args.Domain,
+		AllowFileDiscovery: args.Discoverable,
+	}
+
+	if !args.Expiration.IsZero() {
+		permission.ExpirationTime = args.Expiration.UTC().Format(time.RFC3339)
+	}
+
+	// Ownership is transferred one file at the time with share
+	if err := validatePermission(permission, permissionOptions{notify: !args.NoNotify, message: args.Message}); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(permission)
+	if err != nil {
+		return err
+	}
+
+	// Drive only sends notifications to users and groups
+	params := url.Values{"supportsTeamDrives": {"true"}, "fields": {"id"}}
+	if isUserOrGroup(args.Type) {
+		params.Set("sendNotificationEmail", strconv.FormatBool(!args.NoNotify))
+		if args.Message != "" {
+			params.Set("emailMessage", args.Message)
+		}
+	}
+
+	// Any role counts, Drive would otherwise add a second permission
+	filter := PermissionFilter{Type: args.Type, Email: args.Email, Domain: args.Domain}
+	grantee := describeGrantee(args.Type, args.Email, args.Domain)
+	batcher := newPermissionBatcher(self, args.Out, args.Rate)
+	count := 0
+	skipped := 0
+
+	err = self.forEachShareFile(args.Id, args.Recursive, func(f *drive.File, path string) error {
+		permissions, err := self.filePermissions(f)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range permissions {
+			if !filter.matches(p) {
+				continue
+			}
+			if p.Role != args.Role {
+				fmt.Fprintf(args.Out, "Skipping %s, %s already has %s permission\n", path, grantee, p.Role)
+				skipped++
+			}
+			return nil
+		}
+
+		desc := fmt.Sprintf("%s permission to %s on %s", args.Role, grantee, path)
+		fmt.Fprintf(args.Out, "Granting %s\n", desc)
+		count++
+
+		if args.DryRun {
+			return nil
+		}
+
+		return batcher.add(permissionChange{
+			desc: desc,
+			request: batchRequest{
+				method: "POST",
+				path:   fmt.Sprintf("/drive/v3/files/%s/permissions?%s", url.PathEscape(f.Id), params.Encode()),
+				body:   body,
+			},
+		})
+	})
+
+	if flushErr := batcher.flush(); err == nil {
+		err = flushErr
+	}
+
+	if args.DryRun {
+		fmt.Fprintf(args.Out, "Would grant %d permissions\n", count)
+	} else {
+		fmt.Fprintf(args.Out, "Granted %d permissions\n", batcher.done)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(args.Out, "Skipped %d files where %s has another role, use 'share update' to change it\n", skipped, grantee)
+	}
+
+	if err != nil {
+		return err
+	}
+	if batcher.failed > 0 {
+		return fmt.Errorf("Failed to grant %d permissions", batcher.failed)
+	}
+	return nil
+}
+
+func describeGrantee(permissionType, email, domain string) string {
+	switch permissionType {
+	case "anyone":
+		return "anyone with the link"
+	case "domain":
+		return fmt.Sprintf("domain %s", domain)
+	default:
+		return email
+	}
+}
+
+type permissionChange struct {
+	desc           string
+	ignoreNotFound bool
+	request        batchRequest
+}
+
+// Collects permission changes and sends them in batches, waiting
+// between batches to keep below rate changes per second
+type permissionBatcher struct {
+	drive   *Drive
+	out     io.Writer
+	rate    int64
+	pending []permissionChange
+	done    int
+	failed  int
+}
+
+func newPermissionBatcher(d *Drive, out io.Writer, rate int64) *permissionBatcher {
+	if rate < 1 {
+		rate = DefaultShareRate
+	}
+	return &permissionBatcher{drive: d, out: out, rate: rate}
+}
+
+func (self *permissionBatcher) add(change permissionChange) error {
+	self.pending = append(self.pending, change)
+	if len(self.pending) >= min(int(self.rate), MaxBatchSize) {
+		return self.flush()
+	}
+	return nil
+}
+
+// Sends the pending changes, changes that fail with a backend or
+// rate limit error are retried. Other failures are reported and counted
+func (self *permissionBatcher) flush() error {
+	changes := self.pending
+	self.pending = nil
+
+	for try := 0; len(changes) > 0; try++ {
+		if try > 0 {
+			exponentialBackoffSleep(try - 1)
+		}
+
+		start := time.Now()
+
+		var requests []batchRequest
+		for _, change := range changes {
+			requests = append(requests, change.request)
+		}
+
+		errs, err := self.drive.doBatch(requests)
+		if err != nil {
+			return fmt.Errorf("Failed to send batch request: %s", err)
+		}
+
+		var retry []permissionChange
+		for i, err := range errs {
+			change := changes[i]
+
+			switch {
+			case err == nil, change.ignoreNotFound && isNotFoundError(err):
+				self.done++
+			case isBackendOrRateLimitError(err) && try < MaxErrorRetries:
+				retry = append(retry, change)
+			default:
+				fmt.Fprintf(self.out, "Failed to change %s: %s\n", change.desc, err)
+				self.failed++
+			}
+		}
+
+		// Keep below the rate, a batch counts as one change per request
+		minDuration := time.Duration(len(changes)) * time.Second / time.Duration(self.rate)
+		if elapsed := time.Since(start); elapsed < minDuration {
+			time.Sleep(minDuration - elapsed)
+		}
+
+		changes = retry
+	}
+
+	return nil
+}
+
+func isNotFoundError(err error) bool {
+	ae, ok := err.(*googleapi.Error)
+	return ok && ae.Code == http.StatusNotFound
+}
@@ -7,6 +7,7 @@ import (
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,6 +17,9 @@ import (
 
 const DefaultIgnoreFile = ".godriveignore"
 
+// Fields of the files in a sync root
+const syncFileFields = "id,name,parents,mimeType,size,modifiedTime,createdTime,md5Checksum"
+
 type ModTime int
 
 const (
@@ -41,7 +45,7 @@ const (
 	KeepLargest
 )
 
-func (self *Drive) prepareSyncFiles(localPath string, root *drive.File, cmp FileComparer) (*syncFiles, error) {
+func (self *Drive) prepareSyncFiles(localPath string, root *drive.File, cmp FileComparer, ignore []string) (*syncFiles, error) {
 	localCh := make(chan struct {
 		files []*LocalFile
 		err   error
@@ -52,7 +56,7 @@ func (self *Drive) prepareSyncFiles(localPath string, root *drive.File, cmp File
 	})
 
 	go func() {
-		files, err := prepareLocalFiles(localPath)
+		files, err := prepareLocalFiles(localPath, ignore)
 		localCh <- struct {
 			files []*LocalFile
 			err   error
@@ -77,6 +81,10 @@ func (self *Drive) prepareSyncFiles(localPath string, root *drive.File, cmp File
 		return nil, remote.err
 	}
 
+	if err := self.loadRemoteChecksums(remote.files, cmp); err != nil {
+		return nil, err
+	}
+
 	return &syncFiles{
 		root:    &RemoteFile{file: root},
 		local:   local.files,
@@ -95,7 +103,9 @@ func (self *Drive) isSyncFile(id string) (bool, error) {
 	return ok, nil
 }
 
-func prepareLocalFiles(root string) ([]*LocalFile, error) {
+// Local files matching the patterns in the ignore file
+// or the given ignore patterns are skipped
+func prepareLocalFiles(root string, ignore []string) ([]*LocalFile, error) {
 	var files []*LocalFile
 
 	// Get absolute root path
@@ -105,7 +115,7 @@ func prepareLocalFiles(root string) ([]*LocalFile, error) {
 	}
 
 	// Prepare ignorer
-	shouldIgnore, err := prepareIgnorer(filepath.Join(absRootPath, DefaultIgnoreFile))
+	shouldIgnore, err := prepareIgnorer(filepath.Join(absRootPath, DefaultIgnoreFile), ignore)
 	if err != nil {
 		return nil, err
 	}
@@ -131,6 +141,11 @@ func prepareLocalFiles(root string) ([]*LocalFile, error) {
 			return err
 		}
 
+		// Skip the lock file and the files used to replace it
+		if !info.IsDir() && isLocalLockFile(relPath) {
+			return nil
+		}
+
 		// Skip file if it is ignored by ignore file
 		if shouldIgnore(relPath) {
 			return nil
@@ -153,15 +168,9 @@ func prepareLocalFiles(root string) ([]*LocalFile, error) {
 }
 
 func (self *Drive) prepareRemoteFiles(rootDir *drive.File, sortOrder string) ([]*RemoteFile, error) {
-	// Find all files which has rootDir as root
-	listArgs := listAllFilesArgs{
-		query:     fmt.Sprintf("appProperties has {key='syncRootId' and value='%s'}", rootDir.Id),
-		fields:    []googleapi.Field{"nextPageToken", "files(id,name,parents,md5Checksum,mimeType,size,modifiedTime)"},
-		sortOrder: sortOrder,
-	}
-	files, err := self.listAllFiles(listArgs)
+	files, err := self.listSyncFiles(rootDir, sortOrder)
 	if err != nil {
-		return nil, fmt.Errorf("Failed listing files: %s", err)
+		return nil, err
 	}
 
 	if err := checkFiles(files); err != nil {
@@ -188,6 +197,30 @@ func (self *Drive) prepareRemoteFiles(rootDir *drive.File, sortOrder string) ([]
 	return remoteFiles, nil
 }
 
+// Lists all files which has rootDir as root
+func (self *Drive) listSyncFiles(rootDir *drive.File, sortOrder string) ([]*drive.File, error) {
+	// The cached listing is unordered
+	if self.cache != nil && sortOrder == "" {
+		return self.cachedSyncFiles(rootDir)
+	}
+
+	listArgs := listAllFilesArgs{
+		query:     syncFilesQuery(rootDir.Id),
+		fields:    []googleapi.Field{"nextPageToken", "files(" + syncFileFields + ")"},
+		sortOrder: sortOrder,
+	}
+	files, err := self.listAllFiles(listArgs)
+	if err != nil {
+		return nil, fmt.Errorf("Failed listing files: %s", err)
+	}
+	return files, nil
+}
+
+// Trashed files are left out, as they are when a cached listing is updated
+func syncFilesQuery(rootId string) string {
+	return fmt.Sprintf("appProperties has {key='syncRootId' and value='%s'} and trashed = false", rootId)
+}
+
 func prepareRemoteRelPaths(root *drive.File, files []*drive.File) (map[string]string, error) {
 	// The tree only holds integer values so we use
 	// maps to lookup file by index and index by file id
@@ -285,8 +318,9 @@ type LocalFile struct {
 }
 
 type RemoteFile struct {
-	relPath string
-	file    *drive.File
+	relPath   string
+	file      *drive.File
+	checksums remoteChecksums
 }
 
 type changedFile struct {
@@ -301,8 +335,49 @@ type syncFiles struct {
 	compare FileComparer
 }
 
+// Compares files by the checksum of the algorithm, the remote
+// checksums are loaded before files are compared
 type FileComparer interface {
 	Changed(*LocalFile, *RemoteFile) bool
+	Algorithm() HashAlgorithm
+}
+
+// Fetches the checksums the comparer needs that are not part of drive.File.
+// Fails if drive does not have them for a file, comparing another
+// checksum instead would be weaker than what was asked for
+func (self *Drive) loadRemoteChecksums(files []*RemoteFile, cmp FileComparer) error {
+	algo := cmp.Algorithm()
+
+	var binary []*RemoteFile
+	var ids []string
+	for _, rf := range files {
+		if isBinary(rf.file) {
+			binary = append(binary, rf)
+			ids = append(ids, rf.file.Id)
+		}
+	}
+
+	if algo != Md5 && len(ids) > 0 {
+		sums, err := self.fileChecksums(ids, 0)
+		if err != nil {
+			return err
+		}
+		for _, rf := range binary {
+			rf.checksums = sums[rf.file.Id]
+		}
+	}
+
+	var missing []string
+	for _, rf := range binary {
+		if rf.Checksum(algo) == "" {
+			missing = append(missing, rf.relPath)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Drive has no %s checksum for %d files, first %s. Use another --hash algorithm to compare them", algo, len(missing), missing[0])
+	}
+	return nil
 }
 
 func (self LocalFile) AbsPath() string {
@@ -321,6 +396,15 @@ func (self RemoteFile) Md5() string {
 	return self.file.Md5Checksum
 }
 
+// Returns the checksum for the given algorithm, or an empty string if it
+// is not known. Checksums other than md5 are set by loadRemoteChecksums
+func (self RemoteFile) Checksum(algo HashAlgorithm) string {
+	if algo == Md5 {
+		return self.file.Md5Checksum
+	}
+	return self.checksums.get(algo)
+}
+
 func (self RemoteFile) Size() int64 {
 	return self.file.Size
 }
@@ -588,16 +672,26 @@ func (self byRemotePath) Less(i, j int) bool {
 
 type ignoreFunc func(string) bool
 
-func prepareIgnorer(path string) (ignoreFunc, error) {
+func prepareIgnorer(path string, patterns []string) (ignoreFunc, error) {
 	acceptAll := func(string) bool {
 		return false
 	}
 
-	if !fileExists(path) {
+	var lines []string
+	if fileExists(path) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return acceptAll, fmt.Errorf("Failed to read ignore file: %s", err)
+		}
+		lines = strings.Split(string(content), "\n")
+	}
+	lines = append(lines, patterns...)
+
+	if len(lines) == 0 {
 		return acceptAll, nil
 	}
 
-	ignorer, err := ignore.CompileIgnoreFile(path)
+	ignorer, err := ignore.CompileIgnoreLines(lines...)
 	if err != nil {
 		return acceptAll, fmt.Errorf("Failed to prepare ignorer: %s", err)
 	}
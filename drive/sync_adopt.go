@@ -0,0 +1,208 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io"
+	"text/tabwriter"
+)
+
+type AdoptSyncArgs struct {
+	Out         io.Writer
+	Path        string
+	RootId      string
+	Comparer    FileComparer
+	DryRun      bool
+	ForceUnlock bool
+}
+
+type adoptConflict struct {
+	relPath string
+	reason  string
+}
+
+// Turns an existing directory into a sync root by tagging the remote files
+// that match local files by relative path and content. Files that does not
+// match are reported and left untagged, which makes them invisible to the
+// sync commands. The root is tagged last so an interrupted adopt can be rerun
+func (self *Drive) AdoptSync(args AdoptSyncArgs) error {
+	root, err := self.service.Files.Get(args.RootId).SupportsTeamDrives(true).Fields("id", "name", "mimeType", "appProperties").Do()
+	if err != nil {
+		return fmt.Errorf("Failed to find root dir: %s", err)
+	}
+
+	if !isDir(root) {
+		return fmt.Errorf("Provided root id is not a directory")
+	}
+
+	if _, ok := root.AppProperties["syncRoot"]; ok {
+		return fmt.Errorf("Directory is already a sync root")
+	}
+
+	var lock *Lock
+	if !args.DryRun {
+		lock, err = self.lockSyncRoot(root.Id, args.ForceUnlock)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+
+	fmt.Fprintln(args.Out, "Collecting local and remote file information...")
+	localFiles, err := prepareLocalFiles(args.Path, nil)
+	if err != nil {
+		return err
+	}
+
+	local := map[string]*LocalFile{}
+	for _, lf := range localFiles {
+		local[lf.relPath] = lf
+	}
+
+	// Directories that are part of the sync root, files are
+	// only adopted when their parent directory is adopted
+	adopted := map[string]bool{root.Id: true}
+	seen := map[string]bool{}
+
+	var tag []*RemoteFile
+	var conflicts []adoptConflict
+
+	// Files are collected first so the checksums can be loaded in batches
+	var remote []*RemoteFile
+	err = self.walkFiles(walkFilesArgs{
+		root:   root,
+		fields: []googleapi.Field{"files(id,name,parents,md5Checksum,mimeType,size,modifiedTime,appProperties)"},
+		fn: func(f *drive.File, relPath string) error {
+			if isRemoteLockFile(f) {
+				return nil
+			}
+			remote = append(remote, &RemoteFile{relPath: relPath, file: f})
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := self.loadRemoteChecksums(remote, args.Comparer); err != nil {
+		return err
+	}
+
+	for _, rf := range remote {
+		f := rf.file
+		relPath := rf.relPath
+
+		reason := adoptConflictReason(rf, local[relPath], seen[relPath], args.Comparer)
+		seen[relPath] = true
+
+		if reason == "" && (len(f.Parents) != 1 || !adopted[f.Parents[0]]) {
+			reason = "parent directory not adopted"
+		}
+
+		if reason == "" {
+			if rootId, ok := f.AppProperties["syncRootId"]; ok && rootId != root.Id {
+				reason = "belongs to another sync root"
+			}
+		}
+
+		if reason != "" {
+			conflicts = append(conflicts, adoptConflict{relPath, reason})
+			continue
+		}
+
+		if isDir(f) {
+			adopted[f.Id] = true
+		}
+
+		if f.AppProperties["syncRootId"] != root.Id {
+			tag = append(tag, rf)
+		}
+	}
+
+	for i, rf := range tag {
+		if err := lock.Err(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(args.Out, "[%04d/%04d] Adopting %s\n", i+1, len(tag), rf.relPath)
+
+		if args.DryRun {
+			continue
+		}
+
+		props := map[string]string{"sync": "true", "syncRootId": root.Id}
+		if err := self.setAppProperties(rf.file.Id, props); err != nil {
+			return err
+		}
+	}
+
+	if err := lock.Err(); err != nil {
+		return err
+	}
+
+	if !args.DryRun {
+		props := map[string]string{"sync": "true", "syncRoot": "true"}
+		if err := self.setAppProperties(root.Id, props); err != nil {
+			return err
+		}
+	}
+
+	if len(conflicts) > 0 {
+		fmt.Fprintf(args.Out, "\nFiles not adopted (%d):\n", len(conflicts))
+
+		w := new(tabwriter.Writer)
+		w.Init(args.Out, 0, 0, 3, ' ', 0)
+
+		for _, c := range conflicts {
+			fmt.Fprintf(w, "  %s\t%s\n", c.relPath, c.reason)
+		}
+
+		w.Flush()
+		fmt.Fprintln(args.Out)
+	}
+
+	if args.DryRun {
+		fmt.Fprintf(args.Out, "Would adopt %s with %d files, %d files not adopted\n", root.Name, len(tag), len(conflicts))
+	} else {
+		fmt.Fprintf(args.Out, "Adopted %s with %d files, %d files not adopted\n", root.Name, len(tag), len(conflicts))
+	}
+	return nil
+}
+
+// Returns why a remote file can't be adopted, or an empty string if it matches the local file
+func adoptConflictReason(rf *RemoteFile, lf *LocalFile, duplicate bool, cmp FileComparer) string {
+	if duplicate {
+		return "name collision"
+	}
+
+	if lf == nil {
+		return "missing locally"
+	}
+
+	if isDir(rf.file) != lf.info.IsDir() {
+		return "file type differs"
+	}
+
+	if isDir(rf.file) {
+		return ""
+	}
+
+	if !isBinary(rf.file) {
+		return "not a binary file"
+	}
+
+	if cmp.Changed(lf, rf) {
+		return "content differs"
+	}
+
+	return ""
+}
+
+func (self *Drive) setAppProperties(id string, props map[string]string) error {
+	_, err := self.service.Files.Update(id, &drive.File{AppProperties: props}).SupportsTeamDrives(true).Do()
+	if err != nil {
+		return fmt.Errorf("Failed to update file: %s", err)
+	}
+	return nil
+}
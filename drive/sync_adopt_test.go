@@ -0,0 +1,69 @@
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// Reports every file as changed or unchanged
+type staticComparer bool
+
+func (self staticComparer) Changed(*LocalFile, *RemoteFile) bool {
+	return bool(self)
+}
+
+func (self staticComparer) Algorithm() HashAlgorithm {
+	return Md5
+}
+
+func TestAdoptConflictReason(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godrive-adopt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(path, []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fileInfo, _ := os.Stat(path)
+	dirInfo, _ := os.Stat(dir)
+	localFile := &LocalFile{absPath: path, relPath: "a.txt", info: fileInfo}
+	localDir := &LocalFile{absPath: dir, relPath: "dir", info: dirInfo}
+
+	binary := &drive.File{Md5Checksum: "0cc175b9c0f1b6a831c399e269772661"}
+	document := &drive.File{MimeType: "application/vnd.google-apps.document"}
+	folder := &drive.File{MimeType: DirectoryMimeType}
+
+	tests := []struct {
+		name      string
+		remote    *drive.File
+		local     *LocalFile
+		duplicate bool
+		changed   bool
+		want      string
+	}{
+		{"same file", binary, localFile, false, false, ""},
+		{"same directory", folder, localDir, false, true, ""},
+		{"duplicate", binary, localFile, true, false, "name collision"},
+		{"missing", binary, nil, false, false, "missing locally"},
+		{"file is directory locally", binary, localDir, false, false, "file type differs"},
+		{"directory is file locally", folder, localFile, false, false, "file type differs"},
+		{"document", document, localFile, false, false, "not a binary file"},
+		{"changed", binary, localFile, false, true, "content differs"},
+	}
+
+	for _, test := range tests {
+		rf := &RemoteFile{relPath: "a.txt", file: test.remote}
+		got := adoptConflictReason(rf, test.local, test.duplicate, staticComparer(test.changed))
+		if got != test.want {
+			t.Errorf("%s: adoptConflictReason() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
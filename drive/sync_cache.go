@@ -0,0 +1,86 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"log"
+)
+
+// Returns the files of a sync root from the cache, updated with the
+// changes since it was cached. The full listing is fetched and cached
+// if the root is not cached or the changes could not be listed
+func (self *Drive) cachedSyncFiles(rootDir *drive.File) ([]*drive.File, error) {
+	files, pageToken, err := self.cache.Listing(rootDir.Id)
+	if err != nil {
+		log.Printf("Failed to read cached listing: %s\n", err)
+	}
+
+	if err == nil && pageToken != "" {
+		files, err = self.updateCachedSyncFiles(rootDir, files, pageToken)
+		if err == nil {
+			return files, nil
+		}
+		log.Printf("Failed to update cached listing, listing all files: %s\n", err)
+	}
+
+	// Get the page token before listing so no changes are missed
+	startToken, err := self.service.Changes.GetStartPageToken().SupportsTeamDrives(true).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get start page token: %s", err)
+	}
+
+	listArgs := listAllFilesArgs{
+		query:  syncFilesQuery(rootDir.Id),
+		fields: []googleapi.Field{"nextPageToken", "files(" + syncFileFields + ")"},
+	}
+	files, err = self.listAllFiles(listArgs)
+	if err != nil {
+		return nil, fmt.Errorf("Failed listing files: %s", err)
+	}
+
+	if err := self.cache.PutListing(rootDir.Id, startToken.StartPageToken, files); err != nil {
+		log.Printf("Failed to cache listing: %s\n", err)
+	}
+
+	return files, nil
+}
+
+// Applies the changes since pageToken to the cached files and saves the result
+func (self *Drive) updateCachedSyncFiles(rootDir *drive.File, files []*drive.File, pageToken string) ([]*drive.File, error) {
+	var changed []*drive.File
+	var removed []string
+
+	fields := []googleapi.Field{"nextPageToken", "newStartPageToken", "changes(fileId,removed,file(" + syncFileFields + ",trashed,appProperties))"}
+
+	for {
+		cl, err := self.service.Changes.List(pageToken).SupportsTeamDrives(true).IncludeTeamDriveItems(true).PageSize(1000).Fields(fields...).Do()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range cl.Changes {
+			if c.Removed || c.File == nil || c.File.Trashed || c.File.AppProperties["syncRootId"] != rootDir.Id {
+				removed = append(removed, c.FileId)
+				continue
+			}
+
+			// Properties are only used to filter changes
+			c.File.AppProperties = nil
+			changed = append(changed, c.File)
+		}
+
+		if cl.NewStartPageToken != "" {
+			pageToken = cl.NewStartPageToken
+			break
+		}
+		pageToken = cl.NextPageToken
+	}
+
+	if err := self.cache.UpdateListing(rootDir.Id, pageToken, changed, removed); err != nil {
+		return nil, err
+	}
+
+	files, _, err := self.cache.Listing(rootDir.Id)
+	return files, err
+}
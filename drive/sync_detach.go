@@ -0,0 +1,106 @@
+package drive
+
+import (
+	"fmt"
+	"io"
+)
+
+type DetachSyncArgs struct {
+	Out         io.Writer
+	RootId      string
+	DryRun      bool
+	ForceUnlock bool
+}
+
+// Turns a sync root back into a normal directory by removing the sync
+// appProperties from all its files. Files no longer tagged are not listed
+// again and the root is untagged last, so an interrupted detach can be
+// resumed by running it again. Lock files left in the root are removed
+func (self *Drive) DetachSync(args DetachSyncArgs) error {
+	root, err := self.service.Files.Get(args.RootId).SupportsTeamDrives(true).Fields("id", "name", "mimeType", "appProperties").Do()
+	if err != nil {
+		return fmt.Errorf("Failed to find root dir: %s", err)
+	}
+
+	if !isDir(root) {
+		return fmt.Errorf("Provided root id is not a directory")
+	}
+
+	var lock *Lock
+	if !args.DryRun {
+		lock, err = self.lockSyncRoot(root.Id, args.ForceUnlock)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+
+	files, err := self.listSyncFiles(root, "")
+	if err != nil {
+		return err
+	}
+
+	_, isSyncRoot := root.AppProperties["syncRoot"]
+	if !isSyncRoot && len(files) == 0 {
+		return fmt.Errorf("Provided id is not a sync root directory")
+	}
+
+	props := map[string]string{}
+	for _, key := range SyncAppProperties {
+		props[key] = ""
+	}
+	patch := map[string]interface{}{"appProperties": propertiesPatch(props)}
+
+	for start := 0; start < len(files); start += MaxBatchSize {
+		if err := lock.Err(); err != nil {
+			return err
+		}
+
+		end := start + MaxBatchSize
+		if end > len(files) {
+			end = len(files)
+		}
+
+		var ids []string
+		for _, f := range files[start:end] {
+			ids = append(ids, f.Id)
+		}
+
+		fmt.Fprintf(args.Out, "[%04d/%04d] Removing sync tags\n", end, len(files))
+
+		if args.DryRun {
+			continue
+		}
+
+		if err := self.batchPatchFiles(ids, patch, 0); err != nil {
+			return err
+		}
+	}
+
+	if err := lock.Err(); err != nil {
+		return err
+	}
+
+	if isSyncRoot && !args.DryRun {
+		if _, err := self.patchFile(root.Id, patch, "id"); err != nil {
+			return fmt.Errorf("Failed to update root directory: %s", err)
+		}
+	}
+
+	if !args.DryRun {
+		if err := self.removeRemoteLocks(root.Id, lock); err != nil {
+			return err
+		}
+	}
+
+	if self.cache != nil && !args.DryRun {
+		self.cache.DeleteListing(root.Id)
+	}
+
+	if args.DryRun {
+		fmt.Fprintf(args.Out, "Would detach %s and %d files\n", root.Name, len(files))
+	} else {
+		fmt.Fprintf(args.Out, "Detached %s and %d files\n", root.Name, len(files))
+	}
+	return nil
+}
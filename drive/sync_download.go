@@ -22,6 +22,11 @@ type DownloadSyncArgs struct {
 	Timeout          time.Duration
 	Resolution       ConflictResolution
 	Comparer         FileComparer
+	Ignore           []string
+	ForceUnlock      bool
+
+	// Set while the sync runs, it is checked before each change
+	lock *Lock
 }
 
 func (self *Drive) DownloadSync(args DownloadSyncArgs) error {
@@ -34,8 +39,19 @@ func (self *Drive) DownloadSync(args DownloadSyncArgs) error {
 		return err
 	}
 
+	// Prevent concurrent syncs to the same local directory. The sync root
+	// is not locked, downloading must work with read access to the root
+	if !args.DryRun {
+		lock, err := lockSyncDir(args.Path, args.ForceUnlock)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		args.lock = lock
+	}
+
 	fmt.Fprintln(args.Out, "Collecting file information...")
-	files, err := self.prepareSyncFiles(args.Path, rootDir, args.Comparer)
+	files, err := self.prepareSyncFiles(args.Path, rootDir, args.Comparer, args.Ignore)
 	if err != nil {
 		return err
 	}
@@ -115,6 +131,10 @@ func (self *Drive) createMissingLocalDirs(files *syncFiles, args DownloadSyncArg
 	sort.Sort(byRemotePathLength(missingDirs))
 
 	for i, rf := range missingDirs {
+		if err := args.lock.Err(); err != nil {
+			return err
+		}
+
 		absPath, err := filepath.Abs(filepath.Join(args.Path, rf.relPath))
 		if err != nil {
 			return fmt.Errorf("Failed to determine local absolute path: %s", err)
@@ -140,6 +160,10 @@ func (self *Drive) downloadMissingFiles(files *syncFiles, args DownloadSyncArgs)
 	}
 
 	for i, rf := range missingFiles {
+		if err := args.lock.Err(); err != nil {
+			return err
+		}
+
 		absPath, err := filepath.Abs(filepath.Join(args.Path, rf.relPath))
 		if err != nil {
 			return fmt.Errorf("Failed to determine local absolute path: %s", err)
@@ -163,6 +187,10 @@ func (self *Drive) downloadChangedFiles(changedFiles []*changedFile, args Downlo
 	}
 
 	for i, cf := range changedFiles {
+		if err := args.lock.Err(); err != nil {
+			return err
+		}
+
 		if skip, reason := checkLocalConflict(cf, args.Resolution); skip {
 			fmt.Fprintf(args.Out, "[%04d/%04d] Skipping %s (%s)\n", i+1, changedCount, cf.remote.relPath, reason)
 			continue
@@ -260,6 +288,10 @@ func (self *Drive) deleteExtraneousLocalFiles(files *syncFiles, args DownloadSyn
 	sort.Sort(sort.Reverse(byLocalPathLength(extraneousFiles)))
 
 	for i, lf := range extraneousFiles {
+		if err := args.lock.Err(); err != nil {
+			return err
+		}
+
 		fmt.Fprintf(args.Out, "[%04d/%04d] Deleting %s\n", i+1, extraneousCount, lf.absPath)
 
 		if args.DryRun {
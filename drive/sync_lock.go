@@ -0,0 +1,464 @@
+package drive
+
+import (
+	"crypto/rand"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lock file in the root of a local sync directory and of a sync root, it is never synced
+const LocalLockFilename = ".godrive.lock"
+
+// Locks expire unless they are refreshed by the holder, a lock
+// left behind by a process that died is stale after this time
+const LockTimeout = 5 * time.Minute
+const lockRefreshInterval = time.Minute
+
+// Lock files are replaced through files named by the holder, i.e.
+// '.godrive.lock.host:pid:suffix' and '.godrive.lock.host:pid:suffix.tmp'
+var lockHolderFileRegex = regexp.MustCompile(`^` + regexp.QuoteMeta(LocalLockFilename) + `\.[^:/\\]+:[0-9]+:[0-9a-f]{8}(\.tmp)?$`)
+
+const (
+	syncLockKey        = "syncLock"
+	syncLockHolderKey  = "syncLockHolder"
+	syncLockExpiresKey = "syncLockExpires"
+)
+
+type lockInfo struct {
+	holder  string
+	expires time.Time
+}
+
+func (self lockInfo) stale() bool {
+	return time.Now().After(self.expires)
+}
+
+// Identifies the holder of a lock by host and process, the random
+// suffix tells apart locks taken by the same process
+func lockHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	return fmt.Sprintf("%s:%d:%x", hostname, os.Getpid(), suffix)
+}
+
+type Lock struct {
+	// Id of the lock file of a sync root lock
+	fileId string
+
+	expires time.Time
+	refresh func(time.Time) error
+	release func() error
+	stop    chan struct{}
+	wg      sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// Refreshes the lock in the background until it is released. A lock that
+// was taken over, or that expired because it could not be refreshed in
+// time, is lost and Err reports why
+func (self *Lock) keepAlive() {
+	self.stop = make(chan struct{})
+	self.wg.Add(1)
+
+	go func() {
+		defer self.wg.Done()
+
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := self.refreshOnce(); err != nil {
+					self.mu.Lock()
+					self.err = err
+					self.mu.Unlock()
+					return
+				}
+			case <-self.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Failed refreshes are retried on the next tick until the lock expires,
+// after that someone else may have replaced it as stale
+func (self *Lock) refreshOnce() error {
+	if time.Now().After(self.expires) {
+		return fmt.Errorf("Lock expired at %s without being refreshed", self.expires.Format(time.RFC3339))
+	}
+
+	expires := time.Now().Add(LockTimeout)
+	err := self.refresh(expires)
+	if err == nil {
+		self.expires = expires
+		return nil
+	}
+
+	if _, lost := err.(lostLockError); lost {
+		return err
+	}
+	log.Printf("Failed to refresh lock: %s\n", err)
+	return nil
+}
+
+// Returns the error that made the lock lost, the holder must
+// stop changing the locked files when this is not nil
+func (self *Lock) Err() error {
+	if self == nil {
+		return nil
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.err
+}
+
+func (self *Lock) Release() error {
+	close(self.stop)
+	self.wg.Wait()
+	return self.release()
+}
+
+type lostLockError string
+
+func (self lostLockError) Error() string {
+	return string(self)
+}
+
+// Creates a lock file at path, fails if it is held by someone else.
+// Stale lock files are replaced, force replaces any lock file
+func LockFile(path string, force bool) (*Lock, error) {
+	holder := lockHolder()
+	expires := time.Now().Add(LockTimeout)
+
+	for attempt := 0; ; attempt++ {
+		err := writeLockFile(path, lockInfo{holder, expires}, true)
+		if err == nil {
+			break
+		}
+
+		if !os.IsExist(err) || attempt > 0 {
+			return nil, fmt.Errorf("Failed to create lock file: %s", err)
+		}
+
+		stat, statErr := os.Stat(path)
+		info, err := readLockFile(path)
+		if err == nil && !info.stale() && !force {
+			return nil, fmt.Errorf("%s is locked by %s until %s, use --force-unlock if it is no longer running", path, info.holder, info.expires.Format(time.RFC3339))
+		}
+
+		if statErr == nil {
+			if err := removeLockFile(path, stat, holder); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	lock := &Lock{
+		expires: expires,
+		refresh: func(expires time.Time) error {
+			if info, err := readLockFile(path); err != nil || info.holder != holder {
+				return lostLockError(fmt.Sprintf("Lost lock %s", path))
+			}
+			return writeLockFile(path, lockInfo{holder, expires}, false)
+		},
+		release: func() error {
+			// Leave the lock file if someone forced it away from us
+			if info, err := readLockFile(path); err == nil && info.holder != holder {
+				return nil
+			}
+			return os.Remove(path)
+		},
+	}
+	lock.keepAlive()
+
+	return lock, nil
+}
+
+// Removes the lock file that was found to be stale. Another process may
+// have replaced it in the meantime, so it is first moved away atomically
+// and put back if it turns out to be a different file
+func removeLockFile(path string, stale os.FileInfo, holder string) error {
+	movedPath := path + "." + holder
+	if err := os.Rename(path, movedPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("Failed to remove stale lock file: %s", err)
+	}
+	defer os.Remove(movedPath)
+
+	moved, err := os.Stat(movedPath)
+	if err != nil {
+		return fmt.Errorf("Failed to remove stale lock file: %s", err)
+	}
+
+	if os.SameFile(stale, moved) {
+		return nil
+	}
+
+	// Linking fails instead of replacing a lock file created since
+	os.Link(movedPath, path)
+	info, _ := readLockFile(movedPath)
+	return fmt.Errorf("%s was locked by %s at the same time", path, info.holder)
+}
+
+func writeLockFile(path string, info lockInfo, exclusive bool) error {
+	content := fmt.Sprintf("%s\n%d\n", info.holder, info.expires.Unix())
+
+	if !exclusive {
+		tmpPath := path + "." + info.holder + ".tmp"
+		if err := ioutil.WriteFile(tmpPath, []byte(content), 0600); err != nil {
+			return err
+		}
+		return os.Rename(tmpPath, path)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Unreadable lock files are treated as stale once they are older than the timeout
+func readLockFile(path string) (lockInfo, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return lockInfo{}, err
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) == 2 {
+		if expires, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			return lockInfo{fields[0], time.Unix(expires, 0)}, nil
+		}
+	}
+
+	if stat, err := os.Stat(path); err == nil && time.Since(stat.ModTime()) < LockTimeout {
+		return lockInfo{"unknown", stat.ModTime().Add(LockTimeout)}, nil
+	}
+
+	return lockInfo{}, fmt.Errorf("Invalid lock file %s", path)
+}
+
+// Sets an advisory lock on a sync root. Drive has no conditional updates,
+// so a lock kept in the appProperties of the root could be overwritten by
+// another process between reading and writing it. Instead each holder
+// creates its own lock file in the root and the oldest lock file that is
+// not stale wins, the others remove their lock again. Lock files are not
+// tagged with syncRootId and are never synced. Stale lock files are
+// removed, force removes all other lock files, and detach removes them all
+func (self *Drive) lockSyncRoot(rootId string, force bool) (*Lock, error) {
+	holder := lockHolder()
+	expires := time.Now().Add(LockTimeout)
+
+	lockFile := &drive.File{
+		Name:          LocalLockFilename,
+		Parents:       []string{rootId},
+		AppProperties: remoteLockProperties(rootId, holder, expires),
+	}
+
+	f, err := self.service.Files.Create(lockFile).SupportsTeamDrives(true).Fields("id").Do()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to lock sync root: %s", err)
+	}
+
+	winner, err := self.remoteLockWinner(rootId, f.Id, force)
+	if err != nil || winner.Id != f.Id {
+		self.service.Files.Delete(f.Id).SupportsTeamDrives(true).Do()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if winner.Id != f.Id {
+		info := remoteLockInfo(winner)
+		return nil, fmt.Errorf("Sync root is locked by %s until %s, use --force-unlock if it is no longer running", info.holder, info.expires.Format(time.RFC3339))
+	}
+
+	lock := &Lock{
+		fileId:  f.Id,
+		expires: expires,
+		refresh: func(expires time.Time) error {
+			lf, err := self.service.Files.Get(f.Id).SupportsTeamDrives(true).Fields("trashed").Do()
+			if isNotFoundError(err) || (err == nil && lf.Trashed) {
+				return lostLockError("Lost sync root lock")
+			}
+			if err != nil {
+				return err
+			}
+
+			props := remoteLockProperties(rootId, holder, expires)
+			_, err = self.patchFile(f.Id, map[string]interface{}{"appProperties": props}, "id")
+			return err
+		},
+		release: func() error {
+			err := self.service.Files.Delete(f.Id).SupportsTeamDrives(true).Do()
+			if err != nil && !isNotFoundError(err) {
+				return fmt.Errorf("Failed to unlock sync root: %s", err)
+			}
+			return nil
+		},
+	}
+	lock.keepAlive()
+
+	return lock, nil
+}
+
+// Returns the oldest live lock file in the sync root, ties are broken by id
+// so all holders agree. Stale lock files are removed when possible, if
+// they cannot be removed they are still ignored
+func (self *Drive) remoteLockWinner(rootId, ownId string, force bool) (*drive.File, error) {
+	files, err := self.remoteLockFiles(rootId)
+	if err != nil {
+		return nil, err
+	}
+
+	var winner *drive.File
+	for _, f := range files {
+		if f.Id != ownId && (force || remoteLockInfo(f).stale()) {
+			self.service.Files.Delete(f.Id).SupportsTeamDrives(true).Do()
+			continue
+		}
+
+		if winner == nil || f.CreatedTime < winner.CreatedTime || (f.CreatedTime == winner.CreatedTime && f.Id < winner.Id) {
+			winner = f
+		}
+	}
+
+	if winner == nil {
+		return nil, fmt.Errorf("Failed to find own sync root lock")
+	}
+	return winner, nil
+}
+
+func (self *Drive) remoteLockFiles(rootId string) ([]*drive.File, error) {
+	listArgs := listAllFilesArgs{
+		query:  fmt.Sprintf("'%s' in parents and appProperties has {key='%s' and value='%s'} and trashed = false", rootId, syncLockKey, rootId),
+		fields: []googleapi.Field{"nextPageToken", "files(id,createdTime,appProperties)"},
+	}
+	files, err := self.listAllFiles(listArgs)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list sync root locks: %s", err)
+	}
+	return files, nil
+}
+
+// Deletes the lock files of a sync root except the one of the given
+// lock, which is deleted when it is released
+func (self *Drive) removeRemoteLocks(rootId string, lock *Lock) error {
+	files, err := self.remoteLockFiles(rootId)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.Id == lock.fileId {
+			continue
+		}
+
+		err := self.service.Files.Delete(f.Id).SupportsTeamDrives(true).Do()
+		if err != nil && !isNotFoundError(err) {
+			return fmt.Errorf("Failed to remove lock file: %s", err)
+		}
+	}
+	return nil
+}
+
+func isRemoteLockFile(f *drive.File) bool {
+	_, ok := f.AppProperties[syncLockKey]
+	return ok
+}
+
+func remoteLockProperties(rootId, holder string, expires time.Time) map[string]string {
+	return map[string]string{
+		syncLockKey:        rootId,
+		syncLockHolderKey:  holder,
+		syncLockExpiresKey: strconv.FormatInt(expires.Unix(), 10),
+	}
+}
+
+func remoteLockInfo(f *drive.File) lockInfo {
+	expires, _ := strconv.ParseInt(f.AppProperties[syncLockExpiresKey], 10, 64)
+	return lockInfo{f.AppProperties[syncLockHolderKey], time.Unix(expires, 0)}
+}
+
+// Tells if a path relative to a local sync directory is the lock file
+// or one of the files used to replace it
+func isLocalLockFile(relPath string) bool {
+	return relPath == LocalLockFilename || lockHolderFileRegex.MatchString(relPath)
+}
+
+// Locks a local sync directory
+func lockSyncDir(localPath string, force bool) (*Lock, error) {
+	absPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return nil, err
+	}
+	return LockFile(filepath.Join(absPath, LocalLockFilename), force)
+}
+
+type syncLock struct {
+	local  *Lock
+	remote *Lock
+}
+
+// Locks both the local directory and the sync root, the local lock is
+// taken first so runs on the same machine fail without any requests
+func (self *Drive) lockSync(localPath, rootId string, force bool) (*syncLock, error) {
+	local, err := lockSyncDir(localPath, force)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := self.lockSyncRoot(rootId, force)
+	if err != nil {
+		local.Release()
+		return nil, err
+	}
+
+	return &syncLock{local, remote}, nil
+}
+
+// Returns the error that made either lock lost
+func (self *syncLock) Err() error {
+	if self == nil {
+		return nil
+	}
+	if err := self.local.Err(); err != nil {
+		return err
+	}
+	return self.remote.Err()
+}
+
+func (self *syncLock) Release() error {
+	err := self.remote.Release()
+	self.local.Release()
+	return err
+}
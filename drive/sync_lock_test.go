@@ -0,0 +1,192 @@
+package drive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func TestIsLocalLockFile(t *testing.T) {
+	tests := []struct {
+		relPath string
+		want    bool
+	}{
+		{".godrive.lock", true},
+		{".godrive.lock.host:123:0a1b2c3d", true},
+		{".godrive.lock.host.example.com:123:0a1b2c3d.tmp", true},
+		{".godrive.lock.bak", false},
+		{".godrive.lock.host:123:0a1b2c3d.bak", false},
+		{".godrive.lockfile", false},
+		{"dir/.godrive.lock", false},
+		{"dir/.godrive.lock.host:123:0a1b2c3d", false},
+		{"godrive.lock", false},
+	}
+
+	for _, test := range tests {
+		if got := isLocalLockFile(test.relPath); got != test.want {
+			t.Errorf("isLocalLockFile(%q) = %v, want %v", test.relPath, got, test.want)
+		}
+	}
+}
+
+func TestReadLockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godrive-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	future := time.Now().Add(time.Minute).Unix()
+	past := time.Now().Add(-time.Minute).Unix()
+
+	tests := []struct {
+		content string
+		age     time.Duration
+		holder  string
+		stale   bool
+		err     bool
+	}{
+		{fmt.Sprintf("host:1:0a1b2c3d\n%d\n", future), 0, "host:1:0a1b2c3d", false, false},
+		{fmt.Sprintf("host:1:0a1b2c3d\n%d\n", past), 0, "host:1:0a1b2c3d", true, false},
+
+		// Unreadable lock files may still be written, they are held until they are older than the timeout
+		{"", 0, "unknown", false, false},
+		{"host:1:0a1b2c3d\n", time.Minute, "unknown", false, false},
+		{"host:1:0a1b2c3d\nnot a time\n", 0, "unknown", false, false},
+		{"", 2 * LockTimeout, "", false, true},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("lock%d", i))
+		if err := ioutil.WriteFile(path, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-test.age)
+		os.Chtimes(path, modTime, modTime)
+
+		info, err := readLockFile(path)
+		if test.err {
+			if err == nil {
+				t.Errorf("readLockFile(%q) with age %s succeeded, want error", test.content, test.age)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("readLockFile(%q) with age %s failed: %s", test.content, test.age, err)
+			continue
+		}
+		if info.holder != test.holder || info.stale() != test.stale {
+			t.Errorf("readLockFile(%q) with age %s = %s stale %v, want %s stale %v", test.content, test.age, info.holder, info.stale(), test.holder, test.stale)
+		}
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		force   bool
+		locked  bool
+	}{
+		{"no lock", "", false, true},
+		{"held", fmt.Sprintf("other:1:0a1b2c3d\n%d\n", time.Now().Add(time.Minute).Unix()), false, false},
+		{"held and forced", fmt.Sprintf("other:1:0a1b2c3d\n%d\n", time.Now().Add(time.Minute).Unix()), true, true},
+		{"stale", fmt.Sprintf("other:1:0a1b2c3d\n%d\n", time.Now().Add(-time.Minute).Unix()), false, true},
+		{"unreadable", "garbage", false, false},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "godrive-lock")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, LocalLockFilename)
+		if test.content != "" {
+			if err := ioutil.WriteFile(path, []byte(test.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		lock, err := LockFile(path, test.force)
+		if !test.locked {
+			if err == nil {
+				lock.Release()
+				t.Errorf("%s: LockFile succeeded, want error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: LockFile failed: %s", test.name, err)
+			continue
+		}
+
+		if _, err := LockFile(path, false); err == nil {
+			t.Errorf("%s: second LockFile succeeded while the lock is held", test.name)
+		}
+
+		if err := lock.Release(); err != nil {
+			t.Errorf("%s: Release failed: %s", test.name, err)
+		}
+		if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+			t.Errorf("%s: %d files left after release", test.name, len(files))
+		}
+	}
+}
+
+func TestLockRefreshOnce(t *testing.T) {
+	tests := []struct {
+		name       string
+		expires    time.Duration
+		refreshErr error
+		err        bool
+		refreshed  bool
+	}{
+		{"refreshed", time.Minute, nil, false, true},
+		{"expired", -time.Second, nil, true, false},
+		{"lost", time.Minute, lostLockError("Lost lock"), true, false},
+		{"failed", time.Minute, fmt.Errorf("Network error"), false, false},
+	}
+
+	for _, test := range tests {
+		expires := time.Now().Add(test.expires)
+		lock := &Lock{
+			expires: expires,
+			refresh: func(time.Time) error { return test.refreshErr },
+		}
+
+		err := lock.refreshOnce()
+		if (err != nil) != test.err {
+			t.Errorf("%s: refreshOnce() = %v, want error %v", test.name, err, test.err)
+		}
+		if refreshed := lock.expires.After(expires); refreshed != test.refreshed {
+			t.Errorf("%s: expiry extended %v, want %v", test.name, refreshed, test.refreshed)
+		}
+	}
+}
+
+func TestRemoteLockInfo(t *testing.T) {
+	tests := []struct {
+		props  map[string]string
+		holder string
+		stale  bool
+	}{
+		{remoteLockProperties("root", "host:1:0a1b2c3d", time.Now().Add(time.Minute)), "host:1:0a1b2c3d", false},
+		{remoteLockProperties("root", "host:1:0a1b2c3d", time.Now().Add(-time.Minute)), "host:1:0a1b2c3d", true},
+		{map[string]string{syncLockKey: "root", syncLockHolderKey: "host:1:0a1b2c3d"}, "host:1:0a1b2c3d", true},
+		{map[string]string{syncLockKey: "root", syncLockExpiresKey: "not a time"}, "", true},
+	}
+
+	for _, test := range tests {
+		info := remoteLockInfo(&drive.File{AppProperties: test.props})
+		if info.holder != test.holder || info.stale() != test.stale {
+			t.Errorf("remoteLockInfo(%v) = %s stale %v, want %s stale %v", test.props, info.holder, info.stale(), test.holder, test.stale)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const LostAndFoundName = "lost+found"
+
+type RepairSyncArgs struct {
+	Out         io.Writer
+	RootId      string
+	TrashNewer  bool
+	TrashDirs   bool
+	DryRun      bool
+	ForceUnlock bool
+
+	// Set while the repair runs, it is checked before each change
+	lock *Lock
+}
+
+// Fixes the problems in a sync root that makes the sync commands abort.
+// Extra parents are removed, orphans are moved to a lost+found directory
+// in the root and name collisions are resolved by renaming or trashing
+// all but the oldest file. Directories are only trashed with TrashDirs,
+// as that also trashes their content
+func (self *Drive) RepairSync(args RepairSyncArgs) error {
+	rootDir, err := self.getSyncRoot(args.RootId)
+	if err != nil {
+		return err
+	}
+
+	if !args.DryRun {
+		lock, err := self.lockSyncRoot(rootDir.Id, args.ForceUnlock)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		args.lock = lock
+	}
+
+	files, err := self.listSyncFiles(rootDir, "")
+	if err != nil {
+		return err
+	}
+
+	var fixed int
+
+	n, err := self.repairParents(rootDir, files, args)
+	if err != nil {
+		return err
+	}
+	fixed += n
+
+	tree := analyzeSyncFiles(rootDir, files)
+	if len(tree.orphans) > 0 {
+		lostAndFound, err := self.lostAndFoundDir(rootDir, files, args)
+		if err != nil {
+			return err
+		}
+		files = append(files, lostAndFound)
+
+		n, err = self.repairOrphans(tree.orphans, lostAndFound, args)
+		if err != nil {
+			return err
+		}
+		fixed += n
+	}
+
+	tree = analyzeSyncFiles(rootDir, files)
+	n, err = self.repairCollisions(tree.collisions, files, args)
+	if err != nil {
+		return err
+	}
+	fixed += n
+
+	if fixed == 0 {
+		fmt.Fprintln(args.Out, "No problems found")
+	} else if args.DryRun {
+		fmt.Fprintf(args.Out, "Would fix %d problems, run again with --apply to fix them\n", fixed)
+	} else {
+		fmt.Fprintf(args.Out, "Fixed %d problems\n", fixed)
+	}
+	return nil
+}
+
+// Removes all but one parent from files with multiple parents, preferring
+// a parent inside the sync root. Files are updated in place
+func (self *Drive) repairParents(root *drive.File, files []*drive.File, args RepairSyncArgs) (int, error) {
+	ids := map[string]bool{root.Id: true}
+	for _, f := range files {
+		ids[f.Id] = true
+	}
+
+	var fixed int
+
+	for _, f := range files {
+		if err := args.lock.Err(); err != nil {
+			return fixed, err
+		}
+
+		if len(f.Parents) < 2 {
+			continue
+		}
+
+		keep := f.Parents[0]
+		for _, parentId := range f.Parents {
+			if ids[parentId] {
+				keep = parentId
+				break
+			}
+		}
+
+		var remove []string
+		for _, parentId := range f.Parents {
+			if parentId != keep {
+				remove = append(remove, parentId)
+			}
+		}
+
+		fmt.Fprintf(args.Out, "Removing extra parents %s from %s (%s)\n", formatList(remove), f.Name, f.Id)
+		fixed++
+
+		if !args.DryRun {
+			if err := self.moveFile(f.Id, "", remove); err != nil {
+				return fixed, err
+			}
+		}
+		f.Parents = []string{keep}
+	}
+
+	return fixed, nil
+}
+
+// Moves the topmost orphans to the lost+found directory, their
+// children follow along. Files are updated in place
+func (self *Drive) repairOrphans(orphans []*drive.File, lostAndFound *drive.File, args RepairSyncArgs) (int, error) {
+	orphanIds := map[string]bool{}
+	for _, f := range orphans {
+		orphanIds[f.Id] = true
+	}
+
+	var fixed int
+
+	for _, f := range orphans {
+		if len(f.Parents) > 0 && orphanIds[f.Parents[0]] {
+			continue
+		}
+
+		if err := args.lock.Err(); err != nil {
+			return fixed, err
+		}
+
+		fmt.Fprintf(args.Out, "Moving orphan %s (%s) to %s\n", f.Name, f.Id, LostAndFoundName)
+		fixed++
+
+		if !args.DryRun {
+			if err := self.moveFile(f.Id, lostAndFound.Id, f.Parents); err != nil {
+				return fixed, err
+			}
+		}
+		f.Parents = []string{lostAndFound.Id}
+	}
+
+	return fixed, nil
+}
+
+// Keeps the oldest file of each collision group and renames
+// the others with a numbered suffix or moves them to the trash
+func (self *Drive) repairCollisions(collisions [][]*drive.File, files []*drive.File, args RepairSyncArgs) (int, error) {
+	var fixed int
+
+	for _, group := range collisions {
+		sort.Sort(byCreatedTime(group))
+
+		// Names already taken in the directory
+		names := map[string]bool{}
+		for _, f := range files {
+			if len(f.Parents) > 0 && f.Parents[0] == group[0].Parents[0] {
+				names[f.Name] = true
+			}
+		}
+
+		for _, f := range group[1:] {
+			if err := args.lock.Err(); err != nil {
+				return fixed, err
+			}
+
+			fixed++
+
+			// Trashing a directory trashes its whole subtree
+			trash := args.TrashNewer && (!isDir(f) || args.TrashDirs)
+			if args.TrashNewer && !trash {
+				fmt.Fprintf(args.Out, "Not trashing duplicate directory %s (%s) and its content, use --trash-dirs to trash it\n", f.Name, f.Id)
+			}
+
+			if trash {
+				fmt.Fprintf(args.Out, "Trashing duplicate %s (%s, created %s)\n", f.Name, f.Id, formatDatetime(f.CreatedTime))
+				if args.DryRun {
+					continue
+				}
+				if err := self.trashFile(f.Id); err != nil {
+					return fixed, err
+				}
+				continue
+			}
+
+			name := uniqueName(f.Name, names)
+			names[name] = true
+
+			fmt.Fprintf(args.Out, "Renaming duplicate %s (%s) to %s\n", f.Name, f.Id, name)
+			if args.DryRun {
+				continue
+			}
+
+			_, err := self.service.Files.Update(f.Id, &drive.File{Name: name}).SupportsTeamDrives(true).Do()
+			if err != nil {
+				return fixed, fmt.Errorf("Failed to rename file: %s", err)
+			}
+		}
+	}
+
+	return fixed, nil
+}
+
+// Returns the lost+found directory of the sync root, it is created if missing
+func (self *Drive) lostAndFoundDir(root *drive.File, files []*drive.File, args RepairSyncArgs) (*drive.File, error) {
+	for _, f := range files {
+		if f.Name == LostAndFoundName && isDir(f) && len(f.Parents) == 1 && f.Parents[0] == root.Id {
+			return f, nil
+		}
+	}
+
+	fmt.Fprintf(args.Out, "Creating directory %s\n", LostAndFoundName)
+
+	f, err := self.createMissingRemoteDir(createMissingRemoteDirArgs{
+		name:     LostAndFoundName,
+		parentId: root.Id,
+		rootId:   root.Id,
+		dryRun:   args.DryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Parents are not included in the create response
+	f.Parents = []string{root.Id}
+	return f, nil
+}
+
+func (self *Drive) moveFile(id, addParent string, removeParents []string) error {
+	call := self.service.Files.Update(id, &drive.File{}).SupportsTeamDrives(true)
+
+	if addParent != "" {
+		call = call.AddParents(addParent)
+	}
+
+	if len(removeParents) > 0 {
+		call = call.RemoveParents(strings.Join(removeParents, ","))
+	}
+
+	if _, err := call.Do(); err != nil {
+		return fmt.Errorf("Failed to move file: %s", err)
+	}
+	return nil
+}
+
+// Returns name with the first numbered suffix, i.e. 'name (1).ext',
+// that is not already taken. Names starting with a dot like '.env' have no extension
+func uniqueName(name string, taken map[string]bool) string {
+	ext := filepath.Ext(name)
+	if ext == filepath.Base(name) {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
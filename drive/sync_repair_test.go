@@ -0,0 +1,33 @@
+package drive
+
+import (
+	"testing"
+)
+
+func TestUniqueName(t *testing.T) {
+	tests := []struct {
+		name  string
+		taken []string
+		want  string
+	}{
+		{"a.txt", nil, "a (1).txt"},
+		{"a.txt", []string{"a (1).txt"}, "a (2).txt"},
+		{"a.txt", []string{"a (1).txt", "a (3).txt"}, "a (2).txt"},
+		{"dir/a.tar.gz", nil, "dir/a.tar (1).gz"},
+		{"README", []string{"README (1)"}, "README (2)"},
+		{".env", nil, ".env (1)"},
+		{"dir/.env", nil, "dir/.env (1)"},
+		{".env.local", nil, ".env (1).local"},
+	}
+
+	for _, test := range tests {
+		taken := map[string]bool{}
+		for _, name := range test.taken {
+			taken[name] = true
+		}
+
+		if got := uniqueName(test.name, taken); got != test.want {
+			t.Errorf("uniqueName(%q, %v) = %q, want %q", test.name, test.taken, got, test.want)
+		}
+	}
+}
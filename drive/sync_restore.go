@@ -0,0 +1,180 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type RestoreSyncArgs struct {
+	Out      io.Writer
+	Progress io.Writer
+	RootId   string
+	Path     string
+	At       time.Time
+	DryRun   bool
+	Force    bool
+	Timeout  time.Duration
+}
+
+// Rebuilds the sync root locally as it was at the given point in time,
+// using the revision of each file that was the head revision at that time.
+// Files that have been deleted from drive since can not be restored. Drive
+// keeps no history of parents, so files are named by the original filename
+// of the restored revision and directories created later are left out.
+// Files in those directories were moved since, they are restored at their
+// current path and reported
+func (self *Drive) RestoreSync(args RestoreSyncArgs) error {
+	fmt.Fprintf(args.Out, "Restoring sync root as of %s...\n", args.At.Local().Format("2006-01-02 15:04:05"))
+	started := time.Now()
+
+	rootDir, err := self.getSyncRoot(args.RootId)
+	if err != nil {
+		return err
+	}
+
+	files, err := self.prepareRemoteFiles(rootDir, "")
+	if err != nil {
+		return err
+	}
+
+	// Sort files so that the files with the shortest path comes first
+	sort.Sort(byRemotePathLength(files))
+
+	var restored, skipped, purged, moved int
+
+	// Directories created after the given time, and the paths restored so far
+	laterDirs := map[string]bool{}
+	taken := map[string]bool{}
+
+	for i, rf := range files {
+		createdLater := createdAfter(rf.file, args.At)
+		inLaterDir := laterDirs[filepath.Dir(rf.relPath)]
+
+		if isDir(rf.file) {
+			if createdLater || inLaterDir {
+				laterDirs[rf.relPath] = true
+				continue
+			}
+
+			absPath, err := filepath.Abs(filepath.Join(args.Path, rf.relPath))
+			if err != nil {
+				return fmt.Errorf("Failed to determine local absolute path: %s", err)
+			}
+			if !args.DryRun {
+				os.MkdirAll(absPath, 0775)
+			}
+			continue
+		}
+
+		if !isBinary(rf.file) {
+			continue
+		}
+
+		revisions, err := self.listAllRevisions(rf.file.Id)
+		if err != nil {
+			return err
+		}
+
+		rev := revisionAt(revisions, args.At)
+		if rev == nil && !createdLater {
+			fmt.Fprintf(args.Out, "[%04d/%04d] Skipping %s, its revision at that time has been purged from drive\n", i+1, len(files), rf.relPath)
+			purged++
+			continue
+		}
+		if rev == nil {
+			fmt.Fprintf(args.Out, "[%04d/%04d] Skipping %s, file did not exist\n", i+1, len(files), rf.relPath)
+			skipped++
+			continue
+		}
+
+		relPath := restoredPath(rf.relPath, rev, taken)
+		taken[relPath] = true
+
+		if inLaterDir {
+			fmt.Fprintf(args.Out, "[%04d/%04d] Restoring %s (revision %s, %s), it was moved since and is restored at its current directory\n", i+1, len(files), relPath, rev.Id, formatDatetime(rev.ModifiedTime))
+			moved++
+		} else if relPath != rf.relPath {
+			fmt.Fprintf(args.Out, "[%04d/%04d] Restoring %s as %s (revision %s, %s)\n", i+1, len(files), rf.relPath, relPath, rev.Id, formatDatetime(rev.ModifiedTime))
+		} else {
+			fmt.Fprintf(args.Out, "[%04d/%04d] Restoring %s (revision %s, %s)\n", i+1, len(files), relPath, rev.Id, formatDatetime(rev.ModifiedTime))
+		}
+		restored++
+
+		if args.DryRun {
+			continue
+		}
+
+		absPath, err := filepath.Abs(filepath.Join(args.Path, relPath))
+		if err != nil {
+			return fmt.Errorf("Failed to determine local absolute path: %s", err)
+		}
+
+		_, _, err = self.saveRevision(saveRevisionArgs{
+			out:        args.Out,
+			progress:   args.Progress,
+			fileId:     rf.file.Id,
+			revisionId: rev.Id,
+			fpath:      absPath,
+			force:      args.Force,
+			timeout:    args.Timeout,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(args.Out, "Restored %d files, skipped %d files that did not exist and %d files with purged revisions in %s\n", restored, skipped, purged, time.Since(started))
+	if moved > 0 {
+		fmt.Fprintf(args.Out, "%d files were moved since, they are restored at their current directory\n", moved)
+	}
+	return nil
+}
+
+func createdAfter(f *drive.File, at time.Time) bool {
+	created, err := time.Parse(time.RFC3339, f.CreatedTime)
+	return err == nil && created.After(at)
+}
+
+// Returns the path of a file named as the restored revision. The current
+// name is used when the revision has no name or the path is already taken,
+// and a numbered name when both are taken
+func restoredPath(relPath string, rev *drive.Revision, taken map[string]bool) string {
+	path := relPath
+	if rev.OriginalFilename != "" {
+		path = filepath.Join(filepath.Dir(relPath), rev.OriginalFilename)
+	}
+
+	if !taken[path] {
+		return path
+	}
+	if !taken[relPath] {
+		return relPath
+	}
+	return uniqueName(path, taken)
+}
+
+// Returns the revision that was the head revision at the given time,
+// or nil if the file had no revisions at that time
+func revisionAt(revisions []*drive.Revision, at time.Time) *drive.Revision {
+	var current *drive.Revision
+	var currentTime time.Time
+
+	for _, rev := range revisions {
+		modified, err := time.Parse(time.RFC3339, rev.ModifiedTime)
+		if err != nil || modified.After(at) {
+			continue
+		}
+
+		if current == nil || modified.After(currentTime) {
+			current = rev
+			currentTime = modified
+		}
+	}
+
+	return current
+}
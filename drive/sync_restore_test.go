@@ -0,0 +1,100 @@
+package drive
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func TestRestoredPath(t *testing.T) {
+	tests := []struct {
+		relPath  string
+		original string
+		taken    []string
+		want     string
+	}{
+		{"dir/b.txt", "a.txt", nil, "dir/a.txt"},
+		{"dir/b.txt", "", nil, "dir/b.txt"},
+		{"dir/b.txt", "a.txt", []string{"dir/a.txt"}, "dir/b.txt"},
+		{"dir/b.txt", "", []string{"dir/b.txt"}, "dir/b (1).txt"},
+
+		// Both the original and the current name are taken
+		{"dir/a.txt", "a.txt", []string{"dir/a.txt"}, "dir/a (1).txt"},
+		{"dir/b.txt", "a.txt", []string{"dir/a.txt", "dir/b.txt", "dir/a (1).txt"}, "dir/a (2).txt"},
+	}
+
+	for _, test := range tests {
+		taken := map[string]bool{}
+		for _, path := range test.taken {
+			taken[path] = true
+		}
+
+		got := restoredPath(test.relPath, &drive.Revision{OriginalFilename: test.original}, taken)
+		if got != test.want {
+			t.Errorf("restoredPath(%q, %q, %v) = %q, want %q", test.relPath, test.original, test.taken, got, test.want)
+		}
+	}
+}
+
+// X is now b.txt but was named a.txt, Y is now a.txt. The order the
+// files are restored in must not make one overwrite the other
+func TestRestoredPathSwappedNames(t *testing.T) {
+	x := &drive.Revision{OriginalFilename: "a.txt"}
+	y := &drive.Revision{OriginalFilename: "a.txt"}
+
+	for _, xFirst := range []bool{true, false} {
+		taken := map[string]bool{}
+		var paths []string
+
+		restore := func(relPath string, rev *drive.Revision) {
+			path := restoredPath(relPath, rev, taken)
+			taken[path] = true
+			paths = append(paths, path)
+		}
+
+		if xFirst {
+			restore("b.txt", x)
+			restore("a.txt", y)
+		} else {
+			restore("a.txt", y)
+			restore("b.txt", x)
+		}
+
+		if paths[0] == paths[1] {
+			t.Errorf("x first %v: both files restored to %s", xFirst, paths[0])
+		}
+	}
+}
+
+func TestRevisionAt(t *testing.T) {
+	revisions := []*drive.Revision{
+		{Id: "1", ModifiedTime: "2020-01-01T00:00:00Z"},
+		{Id: "2", ModifiedTime: "2020-02-01T00:00:00Z"},
+		{Id: "bad", ModifiedTime: "not a time"},
+		{Id: "3", ModifiedTime: "2020-03-01T00:00:00Z"},
+	}
+
+	tests := []struct {
+		at   string
+		want string
+	}{
+		{"2019-12-31T00:00:00Z", ""},
+		{"2020-01-01T00:00:00Z", "1"},
+		{"2020-01-15T00:00:00Z", "1"},
+		{"2020-02-01T00:00:01Z", "2"},
+		{"2021-01-01T00:00:00Z", "3"},
+	}
+
+	for _, test := range tests {
+		at, _ := time.Parse(time.RFC3339, test.at)
+
+		got := ""
+		if rev := revisionAt(revisions, at); rev != nil {
+			got = rev.Id
+		}
+		if got != test.want {
+			t.Errorf("revisionAt(%s) = %q, want %q", test.at, got, test.want)
+		}
+	}
+}
@@ -23,6 +23,11 @@ type UploadSyncArgs struct {
 	Timeout          time.Duration
 	Resolution       ConflictResolution
 	Comparer         FileComparer
+	Ignore           []string
+	ForceUnlock      bool
+
+	// Set while the sync runs, it is checked before each change
+	lock *syncLock
 }
 
 func (self *Drive) UploadSync(args UploadSyncArgs) error {
@@ -39,8 +44,18 @@ func (self *Drive) UploadSync(args UploadSyncArgs) error {
 		return err
 	}
 
+	// Prevent concurrent syncs from creating duplicate files
+	if !args.DryRun {
+		lock, err := self.lockSync(args.Path, rootDir.Id, args.ForceUnlock)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		args.lock = lock
+	}
+
 	fmt.Fprintln(args.Out, "Collecting local and remote file information...")
-	files, err := self.prepareSyncFiles(args.Path, rootDir, args.Comparer)
+	files, err := self.prepareSyncFiles(args.Path, rootDir, args.Comparer, args.Ignore)
 	if err != nil {
 		return err
 	}
@@ -120,7 +135,7 @@ func (self *Drive) prepareSyncRoot(args UploadSyncArgs) (*drive.File, error) {
 
 	// Ensure that the directory is empty
 	if !isEmpty {
-		return nil, fmt.Errorf("Root directory is not empty, the initial sync requires an empty directory")
+		return nil, fmt.Errorf("Root directory is not empty, the initial sync requires an empty directory. Use sync adopt to sync an existing directory")
 	}
 
 	// Update directory with syncRoot property
@@ -148,6 +163,10 @@ func (self *Drive) createMissingRemoteDirs(files *syncFiles, args UploadSyncArgs
 	sort.Sort(byLocalPathLength(missingDirs))
 
 	for i, lf := range missingDirs {
+		if err := args.lock.Err(); err != nil {
+			return nil, err
+		}
+
 		parentPath := parentFilePath(lf.relPath)
 		parent, ok := files.findRemoteByPath(parentPath)
 		if !ok {
@@ -192,6 +211,10 @@ func (self *Drive) uploadMissingFiles(missingFiles []*LocalFile, files *syncFile
 	}
 
 	for i, lf := range missingFiles {
+		if err := args.lock.Err(); err != nil {
+			return err
+		}
+
 		parentPath := parentFilePath(lf.relPath)
 		parent, ok := files.findRemoteByPath(parentPath)
 		if !ok {
@@ -217,6 +240,10 @@ func (self *Drive) updateChangedFiles(changedFiles []*changedFile, root *drive.F
 	}
 
 	for i, cf := range changedFiles {
+		if err := args.lock.Err(); err != nil {
+			return err
+		}
+
 		if skip, reason := checkRemoteConflict(cf, args.Resolution); skip {
 			fmt.Fprintf(args.Out, "[%04d/%04d] Skipping %s (%s)\n", i+1, changedCount, cf.local.relPath, reason)
 			continue
@@ -245,6 +272,10 @@ func (self *Drive) deleteExtraneousRemoteFiles(files *syncFiles, args UploadSync
 	sort.Sort(sort.Reverse(byRemotePathLength(extraneousFiles)))
 
 	for i, rf := range extraneousFiles {
+		if err := args.lock.Err(); err != nil {
+			return err
+		}
+
 		fmt.Fprintf(args.Out, "[%04d/%04d] Deleting %s\n", i+1, extraneousCount, filepath.Join(files.root.file.Name, rf.relPath))
 
 		err := self.deleteRemoteFile(rf, args, 0)
@@ -0,0 +1,232 @@
+package drive
+
+import (
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+type VerifySyncArgs struct {
+	Out      io.Writer
+	Path     string
+	RootId   string
+	Comparer FileComparer
+}
+
+// Compares a sync root with a local directory without changing either.
+// Unlike the sync commands, problems with the remote tree are reported
+// instead of aborting on the first one
+func (self *Drive) VerifySync(args VerifySyncArgs) error {
+	rootDir, err := self.getSyncRoot(args.RootId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(args.Out, "Collecting local and remote file information...")
+	local, err := prepareLocalFiles(args.Path, nil)
+	if err != nil {
+		return err
+	}
+
+	files, err := self.listSyncFiles(rootDir, "")
+	if err != nil {
+		return err
+	}
+
+	tree := analyzeSyncFiles(rootDir, files)
+
+	relPaths, err := prepareRemoteRelPaths(rootDir, tree.reachable())
+	if err != nil {
+		return err
+	}
+
+	var remote []*RemoteFile
+	for _, f := range tree.valid {
+		remote = append(remote, &RemoteFile{
+			relPath: relPaths[f.Id],
+			file:    f,
+		})
+	}
+
+	if err := self.loadRemoteChecksums(remote, args.Comparer); err != nil {
+		return err
+	}
+
+	sf := &syncFiles{
+		root:    &RemoteFile{file: rootDir},
+		local:   local,
+		remote:  remote,
+		compare: args.Comparer,
+	}
+
+	fmt.Fprintf(args.Out, "Found %d local files and %d remote files\n", len(local), len(files))
+
+	// Colliding files are left out of the remote files, their local
+	// counterparts are reported with the collision instead of as missing
+	colliding := map[string]bool{}
+	for _, group := range tree.collisions {
+		for _, f := range group {
+			colliding[relPaths[f.Id]] = true
+		}
+	}
+
+	var missing []string
+	for _, lf := range sf.filterExtraneousLocalFiles() {
+		if !colliding[lf.relPath] {
+			missing = append(missing, lf.relPath)
+		}
+	}
+
+	var extraneous []string
+	for _, rf := range sf.filterExtraneousRemoteFiles() {
+		extraneous = append(extraneous, rf.relPath)
+	}
+
+	var changed []string
+	for _, cf := range sf.filterChangedLocalFiles() {
+		changed = append(changed, cf.local.relPath)
+	}
+
+	problems := len(missing) + len(extraneous) + len(changed) + len(tree.orphans) + len(tree.multiParent) + len(tree.collisions)
+
+	printPaths(args.Out, "Missing on drive", missing)
+	printPaths(args.Out, "Extraneous on drive", extraneous)
+	printPaths(args.Out, "Changed", changed)
+	printRemoteProblems(args.Out, "Orphaned (no valid parent)", tree.orphans)
+	printRemoteProblems(args.Out, "Not exactly one parent", tree.multiParent)
+	for _, group := range tree.collisions {
+		dir, ok := relPaths[group[0].Parents[0]]
+		if !ok {
+			dir = rootDir.Name
+		}
+		printRemoteProblems(args.Out, fmt.Sprintf("Name collision in %s", dir), group)
+	}
+
+	fmt.Fprintln(args.Out)
+
+	if problems > 0 {
+		return fmt.Errorf("Sync root does not match local directory, found %d differences", problems)
+	}
+
+	fmt.Fprintln(args.Out, "Sync root matches local directory")
+	return nil
+}
+
+type syncTree struct {
+	// Files with a valid path from the root
+	valid []*drive.File
+
+	// Files that can't be traced back to the root
+	orphans []*drive.File
+
+	// Files that does not have exactly one parent
+	multiParent []*drive.File
+
+	// Groups of files with the same name in the same directory
+	collisions [][]*drive.File
+}
+
+// Sorts the files of a sync root into valid and problematic files.
+// Files with more than one parent are placed by their first parent,
+// colliding files are kept in the tree but left out of valid
+func analyzeSyncFiles(root *drive.File, files []*drive.File) *syncTree {
+	tree := &syncTree{}
+
+	children := map[string][]*drive.File{}
+	for _, f := range files {
+		if len(f.Parents) != 1 {
+			tree.multiParent = append(tree.multiParent, f)
+		}
+		if len(f.Parents) > 0 {
+			children[f.Parents[0]] = append(children[f.Parents[0]], f)
+		}
+	}
+
+	// Walk the tree from the root, files not reached are orphans
+	reached := map[string]bool{root.Id: true}
+	queue := []string{root.Id}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		byName := map[string][]*drive.File{}
+		var names []string
+
+		for _, f := range children[id] {
+			if reached[f.Id] {
+				continue
+			}
+			reached[f.Id] = true
+			queue = append(queue, f.Id)
+
+			if _, ok := byName[f.Name]; !ok {
+				names = append(names, f.Name)
+			}
+			byName[f.Name] = append(byName[f.Name], f)
+		}
+
+		for _, name := range names {
+			group := byName[name]
+			if len(group) > 1 {
+				tree.collisions = append(tree.collisions, group)
+				continue
+			}
+			tree.valid = append(tree.valid, group[0])
+		}
+	}
+
+	for _, f := range files {
+		if !reached[f.Id] {
+			tree.orphans = append(tree.orphans, f)
+		}
+	}
+
+	return tree
+}
+
+// Returns all files with a path from the root, including colliding files
+func (self *syncTree) reachable() []*drive.File {
+	files := append([]*drive.File{}, self.valid...)
+	for _, group := range self.collisions {
+		files = append(files, group...)
+	}
+	return files
+}
+
+func printPaths(out io.Writer, title string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+
+	sort.Strings(paths)
+
+	fmt.Fprintf(out, "\n%s (%d):\n", title, len(paths))
+	for _, path := range paths {
+		fmt.Fprintf(out, "  %s\n", path)
+	}
+}
+
+func printRemoteProblems(out io.Writer, title string, files []*drive.File) {
+	if len(files) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "\n%s (%d):\n", title, len(files))
+
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 0, 3, ' ', 0)
+
+	for _, f := range files {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n",
+			f.Id,
+			f.Name,
+			formatList(f.Parents),
+			formatDatetime(f.ModifiedTime),
+		)
+	}
+
+	w.Flush()
+}
@@ -0,0 +1,80 @@
+package drive
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func TestAnalyzeSyncFiles(t *testing.T) {
+	file := func(id, name string, parents ...string) *drive.File {
+		return &drive.File{Id: id, Name: name, Parents: parents}
+	}
+
+	tests := []struct {
+		name        string
+		files       []*drive.File
+		valid       []string
+		orphans     []string
+		multiParent []string
+		collisions  []string
+	}{
+		{
+			"tree",
+			[]*drive.File{file("a", "a.txt", "root"), file("d", "dir", "root"), file("b", "b.txt", "d")},
+			[]string{"a", "b", "d"}, nil, nil, nil,
+		},
+		{
+			"orphans",
+			[]*drive.File{file("a", "a.txt", "root"), file("x", "x", "gone"), file("y", "y.txt", "x")},
+			[]string{"a"}, []string{"x", "y"}, nil, nil,
+		},
+		{
+			"cycle",
+			[]*drive.File{file("p", "p", "q"), file("q", "q", "p")},
+			nil, []string{"p", "q"}, nil, nil,
+		},
+		{
+			"collision",
+			[]*drive.File{file("a1", "a", "root"), file("a2", "a", "root"), file("b", "b.txt", "a1"), file("c", "a", "a1")},
+			[]string{"b", "c"}, nil, nil, []string{"a1,a2"},
+		},
+		{
+			"multiple parents",
+			[]*drive.File{file("d", "dir", "root"), file("m", "m.txt", "d", "root"), file("n", "n.txt")},
+			[]string{"d", "m"}, []string{"n"}, []string{"m", "n"}, nil,
+		},
+	}
+
+	for _, test := range tests {
+		tree := analyzeSyncFiles(&drive.File{Id: "root"}, test.files)
+
+		var collisions []string
+		for _, group := range tree.collisions {
+			collisions = append(collisions, strings.Join(fileIds(group), ","))
+		}
+
+		got := [][]string{fileIds(tree.valid), fileIds(tree.orphans), fileIds(tree.multiParent), collisions}
+		want := [][]string{test.valid, test.orphans, test.multiParent, test.collisions}
+		names := []string{"valid", "orphans", "multiParent", "collisions"}
+
+		for i := range names {
+			if !reflect.DeepEqual(got[i], want[i]) {
+				t.Errorf("%s: %s = %v, want %v", test.name, names[i], got[i], want[i])
+			}
+		}
+	}
+}
+
+// Returns the sorted ids of files, nil when there are none
+func fileIds(files []*drive.File) []string {
+	var ids []string
+	for _, f := range files {
+		ids = append(ids, f.Id)
+	}
+	sort.Strings(ids)
+	return ids
+}
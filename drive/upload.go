@@ -23,11 +23,13 @@ type UploadArgs struct {
 	Parents     []string
 	Folder      string
 	Mime        string
+	Properties  map[string]string
 	Recursive   bool
 	Share       bool
 	Delete      bool
 	ChunkSize   int64
 	Timeout     time.Duration
+	Hash        HashAlgorithm
 }
 
 const maxRetries = 5
@@ -79,11 +81,11 @@ func (self *Drive) Upload(args UploadArgs) error {
 		return fmt.Errorf("'%s' is a directory, use --recursive to upload directories", info.Name())
 	}
 
-	f, rate, err := self.uploadFile(args)
+	f, rate, exists, err := self.uploadFile(args)
 	if err != nil {
 		return err
 	}
-	if rate == 0 {
+	if exists {
 		log.Printf("Skipped %s, already exists\n", f.Id)
 	} else {
 		log.Printf("Uploaded %s at %s/s, total %s\n", f.Id, formatSize(rate, false), formatSize(f.Size, false))
@@ -122,11 +124,11 @@ func (self *Drive) uploadRecursive(args UploadArgs) (int64, error) {
 			return 0, err
 		}
 	} else if info.Mode().IsRegular() {
-		f, rate, err := self.uploadFile(args)
+		f, rate, exists, err := self.uploadFile(args)
 		if err != nil {
 			return 0, err
 		}
-		if rate == 0 {
+		if exists {
 			log.Printf("Skipped %s (%s), already exists\n", args.Path, f.Id)
 		} else {
 			log.Printf("Uploaded %s at %s/s, total %s\n", f.Id, formatSize(rate, false), formatSize(f.Size, false))
@@ -164,6 +166,7 @@ func (self *Drive) uploadDirectory(args UploadArgs) (int64, error) {
 			Name:        srcFileInfo.Name(),
 			Parents:     args.Parents,
 			Description: args.Description,
+			Properties:  args.Properties,
 		})
 		if err != nil {
 			return 0, err
@@ -194,18 +197,20 @@ func (self *Drive) uploadDirectory(args UploadArgs) (int64, error) {
 	return totalSize, nil
 }
 
-func (self *Drive) uploadFile(args UploadArgs) (*drive.File, int64, error) {
-	md5Channel := make(chan string)
+// Returns the uploaded file, the upload rate and whether a file with the same
+// name and checksum already existed, that file is returned instead of uploading
+func (self *Drive) uploadFile(args UploadArgs) (*drive.File, int64, bool, error) {
+	checksumChannel := make(chan map[HashAlgorithm]string)
 	go func() {
-		md5Channel <- Md5sum(args.Path)
+		checksumChannel <- Checksums(args.Path, []HashAlgorithm{args.Hash})
 	}()
 	srcFile, srcFileInfo, err := openFile(args.Path)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, false, err
 	}
 	defer srcFile.Close()
 
-	dstFile := &drive.File{Description: args.Description}
+	dstFile := &drive.File{Description: args.Description, Properties: args.Properties}
 
 	if args.Name == "" {
 		dstFile.Name = filepath.Base(srcFileInfo.Name())
@@ -224,12 +229,17 @@ func (self *Drive) uploadFile(args UploadArgs) (*drive.File, int64, error) {
 	// if file exists with same name and checksum, skip upload
 	existingFile, err := self.existingFile(dstFile.Parents[0], dstFile.Name)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, false, err
 	}
-	if existingFile != nil {
-		localMd5 := <-md5Channel
-		if localMd5 == existingFile.Md5Checksum {
-			return existingFile, 0, nil
+	var localSums map[HashAlgorithm]string
+	if existingFile != nil && isBinary(existingFile) {
+		localSums = <-checksumChannel
+		remoteSum, err := self.remoteChecksum(existingFile, args.Hash)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if localSums[args.Hash] == remoteSum {
+			return existingFile, 0, true, nil
 		}
 	}
 
@@ -241,38 +251,48 @@ func (self *Drive) uploadFile(args UploadArgs) (*drive.File, int64, error) {
 	for {
 		progressReader := getProgressReader(srcFile, args.Progress, srcFileInfo.Size())
 		reader, ctx := getTimeoutReaderContext(progressReader, args.Timeout)
-		f, err = self.service.Files.Create(dstFile).SupportsTeamDrives(true).Fields("id", "name", "size", "md5Checksum", "webContentLink").Context(ctx).Media(reader, chunkSize).Do()
+		f, err = self.service.Files.Create(dstFile).SupportsTeamDrives(true).Fields("id", "name", "size", "mimeType", "md5Checksum", "webContentLink").Context(ctx).Media(reader, chunkSize).Do()
 		if err != nil {
 			if isTimeoutError(err) {
 				retries++
 				if retries > maxRetries {
-					return nil, 0, fmt.Errorf("Failed to upload after %d timeout retries: %s", retries, err)
+					return nil, 0, false, fmt.Errorf("Failed to upload after %d timeout retries: %s", retries, err)
 				}
 				log.Printf("Retrying in 30 s after timeout: %s\n", err.Error())
 				time.Sleep(timeoutRetryDelay)
 			} else if isBackendOrRateLimitError(err) {
 				retries++
 				if retries > maxRetries {
-					return nil, 0, fmt.Errorf("Failed to upload after %d error retries: %s", retries, err)
+					return nil, 0, false, fmt.Errorf("Failed to upload after %d error retries: %s", retries, err)
 				}
 				log.Printf("Retrying in 5 s after error: %s\n", err.Error())
 				time.Sleep(errorRetryDelay)
 			} else {
-				return nil, 0, fmt.Errorf("Failed to upload file: %s", err)
+				return nil, 0, false, fmt.Errorf("Failed to upload file: %s", err)
 			}
 		} else {
 			break
 		}
 		srcFile.Seek(0, 0)
 	}
-	localMd5 := <-md5Channel
-	if f.Md5Checksum != localMd5 {
-		return nil, 0, fmt.Errorf("Failed to verify uploaded file %s from %s, local checksum %s, remote checksum %s", f.Id, args.Path, localMd5, f.Md5Checksum)
+	if localSums == nil {
+		localSums = <-checksumChannel
+	}
+
+	// Converted documents have no checksum to verify against
+	if isBinary(f) {
+		remoteSum, err := self.remoteChecksum(f, args.Hash)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("Failed to verify uploaded file %s from %s: %s", f.Id, args.Path, err)
+		}
+		if localSum := localSums[args.Hash]; remoteSum != localSum {
+			return nil, 0, false, fmt.Errorf("Failed to verify uploaded file %s from %s, local %s checksum %s, remote %s checksum %s", f.Id, args.Path, args.Hash, localSum, args.Hash, remoteSum)
+		}
 	}
 
 	rate := calcRate(f.Size, started, time.Now())
 
-	return f, rate, nil
+	return f, rate, false, nil
 }
 
 type UploadStreamArgs struct {
@@ -282,6 +302,7 @@ type UploadStreamArgs struct {
 	Description string
 	Parents     []string
 	Mime        string
+	Properties  map[string]string
 	Share       bool
 	ChunkSize   int64
 	Progress    io.Writer
@@ -292,7 +313,7 @@ func (self *Drive) UploadStream(args UploadStreamArgs) error {
 	if args.ChunkSize > intMax()-1 {
 		return fmt.Errorf("Chunk size is to big, max chunk size for this computer is %d", intMax()-1)
 	}
-	dstFile := &drive.File{Name: args.Name, Description: args.Description}
+	dstFile := &drive.File{Name: args.Name, Description: args.Description, Properties: args.Properties}
 	if args.Mime != "" {
 		dstFile.MimeType = args.Mime
 	}
@@ -387,7 +408,7 @@ func (self *Drive) fileQuery(query string) (*drive.File, error) {
 	retries := 0
 	for {
 		var err error
-		result, err = self.service.Files.List().SupportsTeamDrives(true).IncludeTeamDriveItems(true).Q(query).Fields("files(id,name,md5Checksum)").Do()
+		result, err = self.service.Files.List().SupportsTeamDrives(true).IncludeTeamDriveItems(true).Q(query).Fields("files(id,name,mimeType,md5Checksum)").Do()
 		if err != nil {
 			if isBackendOrRateLimitError(err) {
 				retries++
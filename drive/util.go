@@ -1,9 +1,7 @@
 package drive
 
 import (
-	"crypto/md5"
 	"fmt"
-	"io"
 	"math"
 	"os"
 	"path/filepath"
@@ -171,13 +169,5 @@ func openFile(path string) (*os.File, os.FileInfo, error) {
 }
 
 func Md5sum(path string) string {
-	h := md5.New()
-	f, err := os.Open(path)
-	if err != nil {
-		return ""
-	}
-	defer f.Close()
-
-	io.Copy(h, f)
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return Checksum(path, Md5)
 }
@@ -0,0 +1,51 @@
+package drive
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+type walkFunc func(f *drive.File, relPath string) error
+
+type walkFilesArgs struct {
+	root   *drive.File
+	fields []googleapi.Field
+	fn     walkFunc
+}
+
+// Walks the tree below root depth first, calling fn for every file and
+// directory. Children are listed one directory at the time, so only the
+// files of the directories on the current path are held in memory
+func (self *Drive) walkFiles(args walkFilesArgs) error {
+	return self.walkDirectory(args.root, "", args)
+}
+
+func (self *Drive) walkDirectory(parent *drive.File, parentPath string, args walkFilesArgs) error {
+	listArgs := listAllFilesArgs{
+		query:  fmt.Sprintf("'%s' in parents and trashed = false", parent.Id),
+		fields: append([]googleapi.Field{"nextPageToken"}, args.fields...),
+	}
+	files, err := self.listAllFiles(listArgs)
+	if err != nil {
+		return fmt.Errorf("Failed listing files: %s", err)
+	}
+
+	for _, f := range files {
+		relPath := filepath.Join(parentPath, f.Name)
+
+		if err := args.fn(f, relPath); err != nil {
+			return err
+		}
+
+		if isDir(f) {
+			if err := self.walkDirectory(f, relPath, args); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -22,6 +22,12 @@ const DefaultTimeout = 5 * 60
 const DefaultQuery = "trashed = false and 'me' in owners"
 const DefaultShareRole = "reader"
 const DefaultShareType = "anyone"
+const DefaultShareRate = 10
+const DefaultKeepRevisions = 1
+const DefaultDedupeQuery = "trashed = false"
+const DefaultTopEntries = 10
+const DefaultHashAlgorithm = "md5"
+const DefaultParallelism = 1
 
 var DefaultConfigDir = GetDefaultConfigDir()
 
@@ -35,6 +41,8 @@ func main() {
 	log.SetOutput(multi)
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds | log.Lshortfile)
 
+	profile := loadProfile(os.Args[1:])
+
 	globalFlags := []cli.Flag{
 		cli.StringFlag{
 			Name:         "configDir",
@@ -53,14 +61,50 @@ func main() {
 			Description: "Oauth access token, only recommended for short-lived requests because of short lifetime (for advanced users)",
 		},
 		cli.StringFlag{
-			Name:        "serviceAccount",
-			Patterns:    []string{"--service-account"},
-			Description: "Oauth service account filename, used for server to server communication without user interaction (filename path is relative to config dir)",
+			Name:        "clientId",
+			Patterns:    []string{"--client-id"},
+			Description: fmt.Sprintf("Oauth client id, overrides GODRIVE_CLIENT_ID and %s in the config dir", ClientSecretFilename),
+		},
+		cli.StringFlag{
+			Name:        "clientSecret",
+			Patterns:    []string{"--client-secret"},
+			Description: "Oauth client secret, used with --client-id",
+		},
+		cli.StringFlag{
+			Name:         "serviceAccount",
+			Patterns:     []string{"--service-account"},
+			Description:  "Oauth service account filename, used for server to server communication without user interaction (filename path is relative to config dir)",
+			DefaultValue: profile.ServiceAccount,
+		},
+		cli.BoolFlag{
+			Name:         "defaultCredentials",
+			Patterns:     []string{"--default-credentials"},
+			Description:  "Use application default credentials: GOOGLE_APPLICATION_CREDENTIALS, gcloud credentials or the metadata server",
+			DefaultValue: profile.Auth == AuthDefault,
+			OmitValue:    true,
+		},
+		cli.StringFlag{
+			Name:         "subject",
+			Patterns:     []string{"--subject"},
+			Description:  "Oauth service account subject, used to impersonate that user account",
+			DefaultValue: profile.Subject,
 		},
 		cli.StringFlag{
-			Name:        "subject",
-			Patterns:    []string{"--subject"},
-			Description: "Oauth service account subject, used to impersonate that user account",
+			Name:         "tokenStore",
+			Patterns:     []string{"--token-store"},
+			Description:  fmt.Sprintf("Where the oauth token is kept: %s, %s or %s, default: %s", TokenStoreFile, TokenStoreKeyring, TokenStoreEncrypted, TokenStoreFile),
+			DefaultValue: profile.TokenStore,
+		},
+		cli.StringFlag{
+			Name:         "scope",
+			Patterns:     []string{"--scope"},
+			Description:  fmt.Sprintf("Oauth scope: %s, %s or %s, tokens are kept per scope, default: %s", ScopeReadonly, ScopeFile, ScopeFull, ScopeFull),
+			DefaultValue: profile.Scope,
+		},
+		cli.StringFlag{
+			Name:        "profile",
+			Patterns:    []string{"--profile"},
+			Description: fmt.Sprintf("Profile in %s to use, default: %s", ConfigFilename, DefaultProfileName),
 		},
 	}
 
@@ -113,6 +157,22 @@ func main() {
 						Description: "Size in bytes",
 						OmitValue:   true,
 					},
+					cli.StringFlag{
+						Name:        "subjectsFile",
+						Patterns:    []string{"--subjects-file"},
+						Description: "Run for each user in file, one email per line, requires domain-wide delegation",
+					},
+					cli.StringFlag{
+						Name:        "allUsersFrom",
+						Patterns:    []string{"--all-users-from"},
+						Description: "Run for each active user in domain, --subject must be an admin of the domain",
+					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of users to run for at the same time, default: %d", profile.Parallelism),
+						DefaultValue: profile.Parallelism,
+					},
 				),
 			},
 		},
@@ -183,8 +243,8 @@ func main() {
 					cli.IntFlag{
 						Name:         "timeout",
 						Patterns:     []string{"--timeout"},
-						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
-						DefaultValue: DefaultTimeout,
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
 					},
 				),
 			},
@@ -231,10 +291,16 @@ func main() {
 		&cli.Handler{
 			Pattern:     "[global] upload [options] <path>",
 			Description: "Upload file or directory",
-			Callback:    uploadHandler,
+			Callback:    writeCommand(uploadHandler),
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:         "hash",
+						Patterns:     []string{"--hash"},
+						Description:  fmt.Sprintf("Checksum algorithm used to compare files: md5, sha1 or sha256, default: %s", DefaultHashAlgorithm),
+						DefaultValue: DefaultHashAlgorithm,
+					},
 					cli.BoolFlag{
 						Name:        "recursive",
 						Patterns:    []string{"-r", "--recursive"},
@@ -242,9 +308,10 @@ func main() {
 						OmitValue:   true,
 					},
 					cli.StringSliceFlag{
-						Name:        "parent",
-						Patterns:    []string{"-p", "--parent"},
-						Description: "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						Name:         "parent",
+						Patterns:     []string{"-p", "--parent"},
+						Description:  "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						DefaultValue: profile.parents(),
 					},
 					cli.StringFlag{
 						Name:        "folder",
@@ -261,6 +328,11 @@ func main() {
 						Patterns:    []string{"--description"},
 						Description: "File description",
 					},
+					cli.StringSliceFlag{
+						Name:        "property",
+						Patterns:    []string{"--property"},
+						Description: "Custom file property as key=value, can be specified multiple times",
+					},
 					cli.BoolFlag{
 						Name:        "noProgress",
 						Patterns:    []string{"--no-progress"},
@@ -287,14 +359,14 @@ func main() {
 					cli.IntFlag{
 						Name:         "timeout",
 						Patterns:     []string{"--timeout"},
-						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
-						DefaultValue: DefaultTimeout,
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
 					},
 					cli.IntFlag{
 						Name:         "chunksize",
 						Patterns:     []string{"--chunksize"},
-						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", DefaultUploadChunkSize),
-						DefaultValue: DefaultUploadChunkSize,
+						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", profile.ChunkSize),
+						DefaultValue: profile.ChunkSize,
 					},
 				),
 			},
@@ -302,26 +374,32 @@ func main() {
 		&cli.Handler{
 			Pattern:     "[global] upload - [options] <name>",
 			Description: "Upload file from stdin",
-			Callback:    uploadStdinHandler,
+			Callback:    writeCommand(uploadStdinHandler),
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
 					cli.StringSliceFlag{
-						Name:        "parent",
-						Patterns:    []string{"-p", "--parent"},
-						Description: "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						Name:         "parent",
+						Patterns:     []string{"-p", "--parent"},
+						Description:  "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						DefaultValue: profile.parents(),
 					},
 					cli.IntFlag{
 						Name:         "chunksize",
 						Patterns:     []string{"--chunksize"},
-						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", DefaultUploadChunkSize),
-						DefaultValue: DefaultUploadChunkSize,
+						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", profile.ChunkSize),
+						DefaultValue: profile.ChunkSize,
 					},
 					cli.StringFlag{
 						Name:        "description",
 						Patterns:    []string{"--description"},
 						Description: "File description",
 					},
+					cli.StringSliceFlag{
+						Name:        "property",
+						Patterns:    []string{"--property"},
+						Description: "Custom file property as key=value, can be specified multiple times",
+					},
 					cli.StringFlag{
 						Name:        "mime",
 						Patterns:    []string{"--mime"},
@@ -336,8 +414,8 @@ func main() {
 					cli.IntFlag{
 						Name:         "timeout",
 						Patterns:     []string{"--timeout"},
-						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
-						DefaultValue: DefaultTimeout,
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
 					},
 					cli.BoolFlag{
 						Name:        "noProgress",
@@ -351,7 +429,7 @@ func main() {
 		&cli.Handler{
 			Pattern:     "[global] update [options] <fileId> <path>",
 			Description: "Update file, this creates a new revision of the file",
-			Callback:    updateHandler,
+			Callback:    writeCommand(updateHandler),
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
@@ -384,14 +462,14 @@ func main() {
 					cli.IntFlag{
 						Name:         "timeout",
 						Patterns:     []string{"--timeout"},
-						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
-						DefaultValue: DefaultTimeout,
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
 					},
 					cli.IntFlag{
 						Name:         "chunksize",
 						Patterns:     []string{"--chunksize"},
-						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", DefaultUploadChunkSize),
-						DefaultValue: DefaultUploadChunkSize,
+						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", profile.ChunkSize),
+						DefaultValue: profile.ChunkSize,
 					},
 				),
 			},
@@ -412,257 +490,992 @@ func main() {
 				),
 			},
 		},
+		&cli.Handler{
+			Pattern:     "[global] meta get <fileId>",
+			Description: "Show file metadata, including properties and appProperties",
+			Callback:    getMetaHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] meta set [options] <fileId>",
+			Description: "Update file metadata without changing its content",
+			Callback:    writeCommand(setMetaHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:        "description",
+						Patterns:    []string{"--description"},
+						Description: "File description",
+					},
+					cli.StringFlag{
+						Name:        "mime",
+						Patterns:    []string{"--mime"},
+						Description: "Mime type",
+					},
+					cli.StringSliceFlag{
+						Name:        "property",
+						Patterns:    []string{"--property"},
+						Description: "Custom file property as key=value, use key= to remove it, can be specified multiple times",
+					},
+					cli.StringSliceFlag{
+						Name:        "appProperty",
+						Patterns:    []string{"--app-property"},
+						Description: "Private app property as key=value, use key= to remove it, can be specified multiple times",
+					},
+					cli.BoolFlag{
+						Name:        "star",
+						Patterns:    []string{"--star"},
+						Description: "Star file",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "unstar",
+						Patterns:    []string{"--unstar"},
+						Description: "Unstar file",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
 		&cli.Handler{
 			Pattern:     "[global] mkdir [options] <name>",
 			Description: "Create directory",
-			Callback:    mkdirHandler,
+			Callback:    writeCommand(mkdirHandler),
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
 					cli.StringSliceFlag{
-						Name:        "parent",
-						Patterns:    []string{"-p", "--parent"},
-						Description: "Parent id of created directory, can be specified multiple times to give many parents",
+						Name:         "parent",
+						Patterns:     []string{"-p", "--parent"},
+						Description:  "Parent id of created directory, can be specified multiple times to give many parents",
+						DefaultValue: profile.parents(),
 					},
 					cli.StringFlag{
 						Name:        "description",
 						Patterns:    []string{"--description"},
 						Description: "Directory description",
 					},
+					cli.StringSliceFlag{
+						Name:        "property",
+						Patterns:    []string{"--property"},
+						Description: "Custom directory property as key=value, can be specified multiple times",
+					},
 				),
 			},
 		},
 		&cli.Handler{
-			Pattern:     "[global] share [options] <fileId>",
-			Description: "Share file or directory",
-			Callback:    shareHandler,
+			Pattern:     "[global] share audit [options]",
+			Description: "List permissions of My Drive and the files in it with full paths",
+			Callback:    shareAuditHandler,
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Check all files in the tree, default is the directory and its direct children",
+						OmitValue:   true,
+					},
 					cli.StringFlag{
-						Name:         "role",
-						Patterns:     []string{"--role"},
-						Description:  fmt.Sprintf("Share role: owner/writer/commenter/reader, default: %s", DefaultShareRole),
-						DefaultValue: DefaultShareRole,
+						Name:        "type",
+						Patterns:    []string{"--type"},
+						Description: "Only permissions of type: user/group/domain/anyone",
 					},
 					cli.StringFlag{
-						Name:         "type",
-						Patterns:     []string{"--type"},
-						Description:  fmt.Sprintf("Share type: user/group/domain/anyone, default: %s", DefaultShareType),
-						DefaultValue: DefaultShareType,
+						Name:        "role",
+						Patterns:    []string{"--role"},
+						Description: "Only permissions with role: owner/writer/commenter/reader",
 					},
 					cli.StringFlag{
 						Name:        "email",
 						Patterns:    []string{"--email"},
-						Description: "The email address of the user or group to share the file with. Requires 'user' or 'group' as type",
+						Description: "Only permissions of user or group with email",
 					},
 					cli.StringFlag{
 						Name:        "domain",
 						Patterns:    []string{"--domain"},
-						Description: "The name of Google Apps domain. Requires 'domain' as type",
+						Description: "Only permissions of domain",
 					},
 					cli.BoolFlag{
-						Name:        "discoverable",
-						Patterns:    []string{"--discoverable"},
-						Description: "Make file discoverable by search engines",
+						Name:        "skipHeader",
+						Patterns:    []string{"--no-header"},
+						Description: "Dont print the header",
 						OmitValue:   true,
 					},
-					cli.BoolFlag{
-						Name:        "revoke",
-						Patterns:    []string{"--revoke"},
-						Description: "Delete all sharing permissions (owner roles will be skipped)",
-						OmitValue:   true,
+					cli.StringFlag{
+						Name:        "subjectsFile",
+						Patterns:    []string{"--subjects-file"},
+						Description: "Run for each user in file, one email per line, requires domain-wide delegation",
+					},
+					cli.StringFlag{
+						Name:        "allUsersFrom",
+						Patterns:    []string{"--all-users-from"},
+						Description: "Run for each active user in domain, --subject must be an admin of the domain",
+					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of users to run for at the same time, default: %d", profile.Parallelism),
+						DefaultValue: profile.Parallelism,
 					},
 				),
 			},
 		},
 		&cli.Handler{
-			Pattern:     "[global] share list <fileId>",
-			Description: "List files permissions",
-			Callback:    shareListHandler,
-			FlagGroups: cli.FlagGroups{
-				cli.NewFlagGroup("global", globalFlags...),
-			},
-		},
-		&cli.Handler{
-			Pattern:     "[global] share revoke <fileId> <permissionId>",
-			Description: "Revoke permission",
-			Callback:    shareRevokeHandler,
-			FlagGroups: cli.FlagGroups{
-				cli.NewFlagGroup("global", globalFlags...),
-			},
-		},
-		&cli.Handler{
-			Pattern:     "[global] delete [options] <fileId>",
-			Description: "Delete file or directory",
-			Callback:    deleteHandler,
+			Pattern:     "[global] share audit [options] <folderId>",
+			Description: "List permissions of a directory and the files in it with full paths",
+			Callback:    shareAuditHandler,
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
 					cli.BoolFlag{
 						Name:        "recursive",
 						Patterns:    []string{"-r", "--recursive"},
-						Description: "Delete directory and all it's content",
+						Description: "Check all files in the tree, default is the directory and its direct children",
 						OmitValue:   true,
 					},
-				),
-			},
-		},
-		&cli.Handler{
-			Pattern:     "[global] sync list [options]",
-			Description: "List all syncable directories on drive",
-			Callback:    listSyncHandler,
-			FlagGroups: cli.FlagGroups{
-				cli.NewFlagGroup("global", globalFlags...),
-				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:        "type",
+						Patterns:    []string{"--type"},
+						Description: "Only permissions of type: user/group/domain/anyone",
+					},
+					cli.StringFlag{
+						Name:        "role",
+						Patterns:    []string{"--role"},
+						Description: "Only permissions with role: owner/writer/commenter/reader",
+					},
+					cli.StringFlag{
+						Name:        "email",
+						Patterns:    []string{"--email"},
+						Description: "Only permissions of user or group with email",
+					},
+					cli.StringFlag{
+						Name:        "domain",
+						Patterns:    []string{"--domain"},
+						Description: "Only permissions of domain",
+					},
 					cli.BoolFlag{
 						Name:        "skipHeader",
 						Patterns:    []string{"--no-header"},
 						Description: "Dont print the header",
 						OmitValue:   true,
 					},
+					cli.StringFlag{
+						Name:        "subjectsFile",
+						Patterns:    []string{"--subjects-file"},
+						Description: "Run for each user in file, one email per line, requires domain-wide delegation",
+					},
+					cli.StringFlag{
+						Name:        "allUsersFrom",
+						Patterns:    []string{"--all-users-from"},
+						Description: "Run for each active user in domain, --subject must be an admin of the domain",
+					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of users to run for at the same time, default: %d", profile.Parallelism),
+						DefaultValue: profile.Parallelism,
+					},
 				),
 			},
 		},
 		&cli.Handler{
-			Pattern:     "[global] sync content [options] <fileId>",
-			Description: "List content of syncable directory",
-			Callback:    listRecursiveSyncHandler,
+			Pattern:     "[global] share update [options] <fileId> <permissionId>",
+			Description: "Change role or expiration of permission",
+			Callback:    writeCommand(shareUpdateHandler),
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
 					cli.StringFlag{
-						Name:        "sortOrder",
-						Patterns:    []string{"--order"},
-						Description: "Sort order. See https://godoc.org/google.golang.org/api/drive/v3#FilesListCall.OrderBy",
+						Name:        "role",
+						Patterns:    []string{"--role"},
+						Description: "New role: owner/organizer/fileOrganizer/writer/commenter/reader",
 					},
-					cli.IntFlag{
-						Name:         "pathWidth",
-						Patterns:     []string{"--path-width"},
-						Description:  fmt.Sprintf("Width of path column, default: %d, minimum: 9, use 0 for full width", DefaultPathWidth),
-						DefaultValue: DefaultPathWidth,
+					cli.StringFlag{
+						Name:        "expires",
+						Patterns:    []string{"--expires"},
+						Description: "Expiration of user and group permissions as a time or duration from now, i.e. 2006-01-02, 2006-01-02T15:04Z or 30d, at most a year",
 					},
 					cli.BoolFlag{
-						Name:        "skipHeader",
-						Patterns:    []string{"--no-header"},
-						Description: "Dont print the header",
+						Name:        "removeExpiration",
+						Patterns:    []string{"--remove-expiration"},
+						Description: "Remove the expiration of the permission",
 						OmitValue:   true,
 					},
 					cli.BoolFlag{
-						Name:        "sizeInBytes",
-						Patterns:    []string{"--bytes"},
-						Description: "Size in bytes",
+						Name:        "transferOwnership",
+						Patterns:    []string{"--transfer-ownership"},
+						Description: "Transfer ownership to the user, required with role owner",
 						OmitValue:   true,
 					},
 				),
 			},
 		},
 		&cli.Handler{
-			Pattern:     "[global] sync download [options] <fileId> <path>",
-			Description: "Sync drive directory to local directory",
-			Callback:    downloadSyncHandler,
+			Pattern:     "[global] share grant [options] <fileId>",
+			Description: "Grant permission on a directory and the files in it",
+			Callback:    writeCommand(shareGrantHandler),
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
-					cli.BoolFlag{
-						Name:        "keepRemote",
-						Patterns:    []string{"--keep-remote"},
-						Description: "Keep remote file when a conflict is encountered",
-						OmitValue:   true,
+					cli.StringFlag{
+						Name:         "role",
+						Patterns:     []string{"--role"},
+						Description:  fmt.Sprintf("Share role: writer/commenter/reader, default: %s", DefaultShareRole),
+						DefaultValue: DefaultShareRole,
+					},
+					cli.StringFlag{
+						Name:         "type",
+						Patterns:     []string{"--type"},
+						Description:  fmt.Sprintf("Share type: user/group/domain/anyone, default: %s", DefaultShareType),
+						DefaultValue: DefaultShareType,
+					},
+					cli.StringFlag{
+						Name:        "email",
+						Patterns:    []string{"--email"},
+						Description: "The email address of the user or group to share the files with. Requires 'user' or 'group' as type",
+					},
+					cli.StringFlag{
+						Name:        "domain",
+						Patterns:    []string{"--domain"},
+						Description: "The name of Google Apps domain. Requires 'domain' as type",
 					},
 					cli.BoolFlag{
-						Name:        "keepLocal",
-						Patterns:    []string{"--keep-local"},
-						Description: "Keep local file when a conflict is encountered",
+						Name:        "discoverable",
+						Patterns:    []string{"--discoverable"},
+						Description: "Make files discoverable by search engines",
 						OmitValue:   true,
 					},
+					cli.StringFlag{
+						Name:        "expires",
+						Patterns:    []string{"--expires"},
+						Description: "Expiration of user and group permissions as a time or duration from now, i.e. 2006-01-02, 2006-01-02T15:04Z or 30d, at most a year",
+					},
 					cli.BoolFlag{
-						Name:        "keepLargest",
-						Patterns:    []string{"--keep-largest"},
-						Description: "Keep largest file when a conflict is encountered",
+						Name:        "noNotify",
+						Patterns:    []string{"--no-notify"},
+						Description: "Do not send a notification email to the user or group",
 						OmitValue:   true,
 					},
+					cli.StringFlag{
+						Name:        "message",
+						Patterns:    []string{"--message"},
+						Description: "Message to include in the notification email",
+					},
 					cli.BoolFlag{
-						Name:        "deleteExtraneous",
-						Patterns:    []string{"--delete-extraneous"},
-						Description: "Delete extraneous local files",
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Change all files in the tree, default is the directory and its direct children",
 						OmitValue:   true,
 					},
 					cli.BoolFlag{
 						Name:        "dryRun",
 						Patterns:    []string{"--dry-run"},
-						Description: "Show what would have been transferred",
-						OmitValue:   true,
-					},
-					cli.BoolFlag{
-						Name:        "noProgress",
-						Patterns:    []string{"--no-progress"},
-						Description: "Hide progress",
+						Description: "Show what would have been changed",
 						OmitValue:   true,
 					},
 					cli.IntFlag{
-						Name:         "timeout",
-						Patterns:     []string{"--timeout"},
-						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
-						DefaultValue: DefaultTimeout,
+						Name:         "rate",
+						Patterns:     []string{"--rate"},
+						Description:  fmt.Sprintf("Max permission changes per second, changes are sent in batches, default: %d", DefaultShareRate),
+						DefaultValue: DefaultShareRate,
 					},
 				),
 			},
 		},
 		&cli.Handler{
-			Pattern:     "[global] sync upload [options] <path> <fileId>",
-			Description: "Sync local directory to drive",
-			Callback:    uploadSyncHandler,
+			Pattern:     "[global] share revoke [options] <fileId>",
+			Description: "Revoke matching permissions from a directory and the files in it (owner roles will be skipped)",
+			Callback:    writeCommand(shareRevokeAllHandler),
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
-					cli.BoolFlag{
-						Name:        "keepRemote",
-						Patterns:    []string{"--keep-remote"},
-						Description: "Keep remote file when a conflict is encountered",
-						OmitValue:   true,
+					cli.StringFlag{
+						Name:        "type",
+						Patterns:    []string{"--type"},
+						Description: "Only permissions of type: user/group/domain/anyone",
 					},
-					cli.BoolFlag{
-						Name:        "keepLocal",
-						Patterns:    []string{"--keep-local"},
-						Description: "Keep local file when a conflict is encountered",
-						OmitValue:   true,
+					cli.StringFlag{
+						Name:        "role",
+						Patterns:    []string{"--role"},
+						Description: "Only permissions with role: owner/writer/commenter/reader",
 					},
-					cli.BoolFlag{
-						Name:        "keepLargest",
-						Patterns:    []string{"--keep-largest"},
-						Description: "Keep largest file when a conflict is encountered",
-						OmitValue:   true,
+					cli.StringFlag{
+						Name:        "email",
+						Patterns:    []string{"--email"},
+						Description: "Only permissions of user or group with email",
+					},
+					cli.StringFlag{
+						Name:        "domain",
+						Patterns:    []string{"--domain"},
+						Description: "Only permissions of domain",
 					},
 					cli.BoolFlag{
-						Name:        "deleteExtraneous",
-						Patterns:    []string{"--delete-extraneous"},
-						Description: "Delete extraneous remote files",
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Change all files in the tree, default is the directory and its direct children",
 						OmitValue:   true,
 					},
 					cli.BoolFlag{
 						Name:        "dryRun",
 						Patterns:    []string{"--dry-run"},
-						Description: "Show what would have been transferred",
-						OmitValue:   true,
-					},
-					cli.BoolFlag{
-						Name:        "noProgress",
-						Patterns:    []string{"--no-progress"},
-						Description: "Hide progress",
+						Description: "Show what would have been changed",
 						OmitValue:   true,
 					},
 					cli.IntFlag{
-						Name:         "timeout",
-						Patterns:     []string{"--timeout"},
-						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
-						DefaultValue: DefaultTimeout,
+						Name:         "rate",
+						Patterns:     []string{"--rate"},
+						Description:  fmt.Sprintf("Max permission changes per second, changes are sent in batches, default: %d", DefaultShareRate),
+						DefaultValue: DefaultShareRate,
 					},
-					cli.IntFlag{
-						Name:         "chunksize",
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] share [options] <fileId>",
+			Description: "Share file or directory",
+			Callback:    writeCommand(shareHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:         "role",
+						Patterns:     []string{"--role"},
+						Description:  fmt.Sprintf("Share role: owner/organizer/fileOrganizer/writer/commenter/reader, default: %s", DefaultShareRole),
+						DefaultValue: DefaultShareRole,
+					},
+					cli.StringFlag{
+						Name:         "type",
+						Patterns:     []string{"--type"},
+						Description:  fmt.Sprintf("Share type: user/group/domain/anyone, default: %s", DefaultShareType),
+						DefaultValue: DefaultShareType,
+					},
+					cli.StringFlag{
+						Name:        "email",
+						Patterns:    []string{"--email"},
+						Description: "The email address of the user or group to share the file with. Requires 'user' or 'group' as type",
+					},
+					cli.StringFlag{
+						Name:        "domain",
+						Patterns:    []string{"--domain"},
+						Description: "The name of Google Apps domain. Requires 'domain' as type",
+					},
+					cli.BoolFlag{
+						Name:        "discoverable",
+						Patterns:    []string{"--discoverable"},
+						Description: "Make file discoverable by search engines",
+						OmitValue:   true,
+					},
+					cli.StringFlag{
+						Name:        "expires",
+						Patterns:    []string{"--expires"},
+						Description: "Expiration of user and group permissions as a time or duration from now, i.e. 2006-01-02, 2006-01-02T15:04Z or 30d, at most a year",
+					},
+					cli.BoolFlag{
+						Name:        "noNotify",
+						Patterns:    []string{"--no-notify"},
+						Description: "Do not send a notification email to the user or group",
+						OmitValue:   true,
+					},
+					cli.StringFlag{
+						Name:        "message",
+						Patterns:    []string{"--message"},
+						Description: "Message to include in the notification email",
+					},
+					cli.BoolFlag{
+						Name:        "transferOwnership",
+						Patterns:    []string{"--transfer-ownership"},
+						Description: "Transfer ownership to the user, required with role owner",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "revoke",
+						Patterns:    []string{"--revoke"},
+						Description: "Delete all sharing permissions (owner roles will be skipped)",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] share list <fileId>",
+			Description: "List files permissions",
+			Callback:    shareListHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] share revoke <fileId> <permissionId>",
+			Description: "Revoke permission",
+			Callback:    writeCommand(shareRevokeHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] delete [options] <fileId>",
+			Description: "Delete file or directory",
+			Callback:    writeCommand(deleteHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Delete directory and all it's content",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] dedupe [options]",
+			Description: "Find duplicate files, and optionally trash all but one of each",
+			Callback:    dedupeHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:         "query",
+						Patterns:     []string{"-q", "--query"},
+						Description:  fmt.Sprintf(`Default query: "%s". See https://developers.google.com/drive/search-parameters`, DefaultDedupeQuery),
+						DefaultValue: DefaultDedupeQuery,
+					},
+					cli.StringFlag{
+						Name:         "by",
+						Patterns:     []string{"--by"},
+						Description:  "Find duplicates by md5 (same checksum and size) or name (same name in the same directory), default: md5",
+						DefaultValue: "md5",
+					},
+					cli.StringFlag{
+						Name:        "keep",
+						Patterns:    []string{"--keep"},
+						Description: "Show which duplicates would be trashed to keep only the oldest or newest file",
+					},
+					cli.BoolFlag{
+						Name:        "trash",
+						Patterns:    []string{"--trash"},
+						Description: "Trash the duplicates selected by --keep, files you cannot trash are skipped",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "sizeInBytes",
+						Patterns:    []string{"--bytes"},
+						Description: "Size in bytes",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] du [options]",
+			Description: "Show storage usage of My Drive by directory, file and type",
+			Callback:    diskUsageHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.IntFlag{
+						Name:         "depth",
+						Patterns:     []string{"--depth"},
+						Description:  "Max depth of directories to report, use 0 for no limit, default: 0",
+						DefaultValue: 0,
+					},
+					cli.IntFlag{
+						Name:         "top",
+						Patterns:     []string{"--top"},
+						Description:  fmt.Sprintf("Number of largest directories and files to show, default: %d", DefaultTopEntries),
+						DefaultValue: DefaultTopEntries,
+					},
+					cli.BoolFlag{
+						Name:        "sizeInBytes",
+						Patterns:    []string{"--bytes"},
+						Description: "Size in bytes",
+						OmitValue:   true,
+					},
+					cli.StringFlag{
+						Name:        "subjectsFile",
+						Patterns:    []string{"--subjects-file"},
+						Description: "Run for each user in file, one email per line, requires domain-wide delegation",
+					},
+					cli.StringFlag{
+						Name:        "allUsersFrom",
+						Patterns:    []string{"--all-users-from"},
+						Description: "Run for each active user in domain, --subject must be an admin of the domain",
+					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of users to run for at the same time, default: %d", profile.Parallelism),
+						DefaultValue: profile.Parallelism,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] du [options] <fileId>",
+			Description: "Show storage usage of directory by subdirectory, file and type",
+			Callback:    diskUsageHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.IntFlag{
+						Name:         "depth",
+						Patterns:     []string{"--depth"},
+						Description:  "Max depth of directories to report, use 0 for no limit, default: 0",
+						DefaultValue: 0,
+					},
+					cli.IntFlag{
+						Name:         "top",
+						Patterns:     []string{"--top"},
+						Description:  fmt.Sprintf("Number of largest directories and files to show, default: %d", DefaultTopEntries),
+						DefaultValue: DefaultTopEntries,
+					},
+					cli.BoolFlag{
+						Name:        "sizeInBytes",
+						Patterns:    []string{"--bytes"},
+						Description: "Size in bytes",
+						OmitValue:   true,
+					},
+					cli.StringFlag{
+						Name:        "subjectsFile",
+						Patterns:    []string{"--subjects-file"},
+						Description: "Run for each user in file, one email per line, requires domain-wide delegation",
+					},
+					cli.StringFlag{
+						Name:        "allUsersFrom",
+						Patterns:    []string{"--all-users-from"},
+						Description: "Run for each active user in domain, --subject must be an admin of the domain",
+					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of users to run for at the same time, default: %d", profile.Parallelism),
+						DefaultValue: profile.Parallelism,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] hash [options] <target>",
+			Description: "Print checksums of local path or drive file",
+			Callback:    hashHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringSliceFlag{
+						Name:        "algorithm",
+						Patterns:    []string{"-a", "--algorithm"},
+						Description: fmt.Sprintf("Checksum algorithm: md5, sha1 or sha256, can be specified multiple times, default: %s", DefaultHashAlgorithm),
+					},
+					cli.BoolFlag{
+						Name:        "skipHeader",
+						Patterns:    []string{"--no-header"},
+						Description: "Dont print the header",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] hash [options] <path> <fileId>",
+			Description: "Print checksums of local path and drive file side by side",
+			Callback:    hashHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringSliceFlag{
+						Name:        "algorithm",
+						Patterns:    []string{"-a", "--algorithm"},
+						Description: fmt.Sprintf("Checksum algorithm: md5, sha1 or sha256, can be specified multiple times, default: %s", DefaultHashAlgorithm),
+					},
+					cli.BoolFlag{
+						Name:        "skipHeader",
+						Patterns:    []string{"--no-header"},
+						Description: "Dont print the header",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync list [options]",
+			Description: "List all syncable directories on drive",
+			Callback:    listSyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "skipHeader",
+						Patterns:    []string{"--no-header"},
+						Description: "Dont print the header",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync content [options] <fileId>",
+			Description: "List content of syncable directory",
+			Callback:    listRecursiveSyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:        "sortOrder",
+						Patterns:    []string{"--order"},
+						Description: "Sort order. See https://godoc.org/google.golang.org/api/drive/v3#FilesListCall.OrderBy",
+					},
+					cli.IntFlag{
+						Name:         "pathWidth",
+						Patterns:     []string{"--path-width"},
+						Description:  fmt.Sprintf("Width of path column, default: %d, minimum: 9, use 0 for full width", DefaultPathWidth),
+						DefaultValue: DefaultPathWidth,
+					},
+					cli.BoolFlag{
+						Name:        "skipHeader",
+						Patterns:    []string{"--no-header"},
+						Description: "Dont print the header",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "sizeInBytes",
+						Patterns:    []string{"--bytes"},
+						Description: "Size in bytes",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync download [options] <fileId> <path>",
+			Description: "Sync drive directory to local directory",
+			Callback:    downloadSyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:         "hash",
+						Patterns:     []string{"--hash"},
+						Description:  fmt.Sprintf("Checksum algorithm used to compare files: md5, sha1 or sha256, default: %s", DefaultHashAlgorithm),
+						DefaultValue: DefaultHashAlgorithm,
+					},
+					cli.BoolFlag{
+						Name:        "keepRemote",
+						Patterns:    []string{"--keep-remote"},
+						Description: "Keep remote file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepLocal",
+						Patterns:    []string{"--keep-local"},
+						Description: "Keep local file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepLargest",
+						Patterns:    []string{"--keep-largest"},
+						Description: "Keep largest file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "deleteExtraneous",
+						Patterns:    []string{"--delete-extraneous"},
+						Description: "Delete extraneous local files",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "forceUnlock",
+						Patterns:    []string{"--force-unlock"},
+						Description: "Remove an existing lock on the local directory, only use when no other sync is running",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "dryRun",
+						Patterns:    []string{"--dry-run"},
+						Description: "Show what would have been transferred",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "noProgress",
+						Patterns:    []string{"--no-progress"},
+						Description: "Hide progress",
+						OmitValue:   true,
+					},
+					cli.IntFlag{
+						Name:         "timeout",
+						Patterns:     []string{"--timeout"},
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync upload [options] <path> <fileId>",
+			Description: "Sync local directory to drive",
+			Callback:    writeCommand(uploadSyncHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:         "hash",
+						Patterns:     []string{"--hash"},
+						Description:  fmt.Sprintf("Checksum algorithm used to compare files: md5, sha1 or sha256, default: %s", DefaultHashAlgorithm),
+						DefaultValue: DefaultHashAlgorithm,
+					},
+					cli.BoolFlag{
+						Name:        "keepRemote",
+						Patterns:    []string{"--keep-remote"},
+						Description: "Keep remote file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepLocal",
+						Patterns:    []string{"--keep-local"},
+						Description: "Keep local file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "keepLargest",
+						Patterns:    []string{"--keep-largest"},
+						Description: "Keep largest file when a conflict is encountered",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "deleteExtraneous",
+						Patterns:    []string{"--delete-extraneous"},
+						Description: "Delete extraneous remote files",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "forceUnlock",
+						Patterns:    []string{"--force-unlock"},
+						Description: "Remove existing locks on the local directory and sync root, only use when no other sync is running",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "dryRun",
+						Patterns:    []string{"--dry-run"},
+						Description: "Show what would have been transferred",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "noProgress",
+						Patterns:    []string{"--no-progress"},
+						Description: "Hide progress",
+						OmitValue:   true,
+					},
+					cli.IntFlag{
+						Name:         "timeout",
+						Patterns:     []string{"--timeout"},
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
+					},
+					cli.IntFlag{
+						Name:         "chunksize",
+						Patterns:     []string{"--chunksize"},
+						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", profile.ChunkSize),
+						DefaultValue: profile.ChunkSize,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync restore [options] <fileId> <path>",
+			Description: "Restore sync root to local directory as it was at a given time",
+			Callback:    restoreSyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:        "at",
+						Patterns:    []string{"--at"},
+						Description: "Point in time to restore, i.e. 2006-01-02T15:04Z or 2006-01-02",
+					},
+					cli.BoolFlag{
+						Name:        "force",
+						Patterns:    []string{"-f", "--force"},
+						Description: "Overwrite existing files",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "dryRun",
+						Patterns:    []string{"--dry-run"},
+						Description: "Show what would have been restored",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "noProgress",
+						Patterns:    []string{"--no-progress"},
+						Description: "Hide progress",
+						OmitValue:   true,
+					},
+					cli.IntFlag{
+						Name:         "timeout",
+						Patterns:     []string{"--timeout"},
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync verify [options] <path> <fileId>",
+			Description: "Verify that local directory matches sync root",
+			Callback:    verifySyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:         "hash",
+						Patterns:     []string{"--hash"},
+						Description:  fmt.Sprintf("Checksum algorithm used to compare files: md5, sha1 or sha256, default: %s", DefaultHashAlgorithm),
+						DefaultValue: DefaultHashAlgorithm,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync repair [options] <fileId>",
+			Description: "Fix name collisions, extra parents and orphans in sync root, shows the fixes unless --apply is given",
+			Callback:    repairSyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "trashNewer",
+						Patterns:    []string{"--trash-newer"},
+						Description: "Trash newer files on name collision instead of renaming them, directories are renamed unless --trash-dirs is given",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "trashDirs",
+						Patterns:    []string{"--trash-dirs"},
+						Description: "Also trash newer directories and their content with --trash-newer",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "forceUnlock",
+						Patterns:    []string{"--force-unlock"},
+						Description: "Remove existing locks on the sync root, only use when no other sync is running",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "apply",
+						Patterns:    []string{"--apply"},
+						Description: "Apply the fixes, by default they are only shown",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync adopt [options] <path> <fileId>",
+			Description: "Make existing drive directory a sync root by matching it with local directory",
+			Callback:    writeCommand(adoptSyncHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:         "hash",
+						Patterns:     []string{"--hash"},
+						Description:  fmt.Sprintf("Checksum algorithm used to compare files: md5, sha1 or sha256, default: %s", DefaultHashAlgorithm),
+						DefaultValue: DefaultHashAlgorithm,
+					},
+					cli.BoolFlag{
+						Name:        "dryRun",
+						Patterns:    []string{"--dry-run"},
+						Description: "Show what would have been adopted",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "forceUnlock",
+						Patterns:    []string{"--force-unlock"},
+						Description: "Remove existing locks on the sync root, only use when no other sync is running",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync detach [options] <fileId>",
+			Description: "Turn sync root back into a normal directory",
+			Callback:    writeCommand(detachSyncHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "dryRun",
+						Patterns:    []string{"--dry-run"},
+						Description: "Show what would have been detached",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "forceUnlock",
+						Patterns:    []string{"--force-unlock"},
+						Description: "Remove existing locks on the sync root, only use when no other sync is running",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] sync run [options] <job>...",
+			Description: "Run sync jobs from the jobs file, all jobs if none are given",
+			Callback:    runSyncHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.StringFlag{
+						Name:        "jobsFile",
+						Patterns:    []string{"--jobs-file"},
+						Description: fmt.Sprintf("Path to jobs file, default: %s in the config dir", JobsFilename),
+					},
+					cli.IntFlag{
+						Name:         "parallel",
+						Patterns:     []string{"--parallel"},
+						Description:  fmt.Sprintf("Number of jobs to run at the same time, default: %d", profile.Parallelism),
+						DefaultValue: profile.Parallelism,
+					},
+					cli.BoolFlag{
+						Name:        "force",
+						Patterns:    []string{"-f", "--force"},
+						Description: "Run jobs even if their schedule says they are not due",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "forceUnlock",
+						Patterns:    []string{"--force-unlock"},
+						Description: "Remove existing locks on the local directory and sync root, only use when no other sync is running",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "dryRun",
+						Patterns:    []string{"--dry-run"},
+						Description: "Show what would have been transferred",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "noProgress",
+						Patterns:    []string{"--no-progress"},
+						Description: "Hide progress, always hidden when running jobs in parallel",
+						OmitValue:   true,
+					},
+					cli.IntFlag{
+						Name:         "timeout",
+						Patterns:     []string{"--timeout"},
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
+					},
+					cli.IntFlag{
+						Name:         "chunksize",
 						Patterns:     []string{"--chunksize"},
-						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", DefaultUploadChunkSize),
-						DefaultValue: DefaultUploadChunkSize,
+						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", profile.ChunkSize),
+						DefaultValue: profile.ChunkSize,
 					},
 				),
 			},
@@ -768,8 +1581,8 @@ func main() {
 					cli.IntFlag{
 						Name:         "timeout",
 						Patterns:     []string{"--timeout"},
-						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", DefaultTimeout),
-						DefaultValue: DefaultTimeout,
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
 					},
 				),
 			},
@@ -777,22 +1590,118 @@ func main() {
 		&cli.Handler{
 			Pattern:     "[global] revision delete <fileId> <revId>",
 			Description: "Delete file revision",
-			Callback:    deleteRevisionHandler,
+			Callback:    writeCommand(deleteRevisionHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] revision keep [options] <fileId> <revId>",
+			Description: "Keep revision forever, revId can also be 'head' or 'previous'",
+			Callback:    writeCommand(keepRevisionHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "unset",
+						Patterns:    []string{"--unset"},
+						Description: "Stop keeping the revision forever",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Apply to all files in directory, revId must be 'head' or 'previous'",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] revision restore [options] <fileId> <revId>",
+			Description: "Restore revision as the head revision, revId can also be 'previous'",
+			Callback:    writeCommand(restoreRevisionHandler),
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Restore all files in directory, revId must be 'head' or 'previous'",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "noProgress",
+						Patterns:    []string{"--no-progress"},
+						Description: "Hide progress",
+						OmitValue:   true,
+					},
+					cli.IntFlag{
+						Name:         "timeout",
+						Patterns:     []string{"--timeout"},
+						Description:  fmt.Sprintf("Set timeout in seconds, use 0 for no timeout. Timeout is reached when no data is transferred in set amount of seconds, default: %d", profile.Timeout),
+						DefaultValue: profile.Timeout,
+					},
+					cli.IntFlag{
+						Name:         "chunksize",
+						Patterns:     []string{"--chunksize"},
+						Description:  fmt.Sprintf("Set chunk size in bytes, default: %d", profile.ChunkSize),
+						DefaultValue: profile.ChunkSize,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] revision prune [options] <fileId>",
+			Description: "Delete old revisions",
+			Callback:    writeCommand(pruneRevisionsHandler),
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.IntFlag{
+						Name:         "keepLast",
+						Patterns:     []string{"--keep-last"},
+						Description:  fmt.Sprintf("Number of revisions to keep, default: %d", DefaultKeepRevisions),
+						DefaultValue: DefaultKeepRevisions,
+					},
+					cli.StringFlag{
+						Name:        "olderThan",
+						Patterns:    []string{"--older-than"},
+						Description: "Required, only delete revisions older than this, i.e. 90d or 12h",
+					},
+					cli.BoolFlag{
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Prune all files in directory",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "dryRun",
+						Patterns:    []string{"--dry-run"},
+						Description: "Show what would have been deleted",
+						OmitValue:   true,
+					},
+					cli.BoolFlag{
+						Name:        "sizeInBytes",
+						Patterns:    []string{"--bytes"},
+						Description: "Size in bytes",
+						OmitValue:   true,
+					},
+				),
 			},
 		},
 		&cli.Handler{
 			Pattern:     "[global] import [options] <path>",
 			Description: "Upload and convert file to a google document, see 'about import' for available conversions",
-			Callback:    importHandler,
+			Callback:    writeCommand(importHandler),
 			FlagGroups: cli.FlagGroups{
 				cli.NewFlagGroup("global", globalFlags...),
 				cli.NewFlagGroup("options",
 					cli.StringSliceFlag{
-						Name:        "parent",
-						Patterns:    []string{"-p", "--parent"},
-						Description: "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						Name:         "parent",
+						Patterns:     []string{"-p", "--parent"},
+						Description:  "Parent id, used to upload file to a specific directory, can be specified multiple times to give many parents",
+						DefaultValue: profile.parents(),
 					},
 					cli.BoolFlag{
 						Name:        "noProgress",
@@ -803,7 +1712,24 @@ func main() {
 					cli.StringFlag{
 						Name:        "mime",
 						Patterns:    []string{"--mime"},
-						Description: "Mime type of imported file",
+						Description: "Mime type of imported file, with --recursive it is used for every file",
+					},
+					cli.BoolFlag{
+						Name:        "recursive",
+						Patterns:    []string{"-r", "--recursive"},
+						Description: "Import directory recursively, files that can't be converted are uploaded as is",
+						OmitValue:   true,
+					},
+					cli.StringSliceFlag{
+						Name:        "convert",
+						Patterns:    []string{"--convert"},
+						Description: "Target mime type for a file extension, i.e. csv=application/vnd.google-apps.document, can be specified multiple times. With --recursive files that can not be converted to it are skipped",
+					},
+					cli.BoolFlag{
+						Name:        "skipUnsupported",
+						Patterns:    []string{"--skip-unsupported"},
+						Description: "Skip files that can't be converted instead of uploading them as is",
+						OmitValue:   true,
 					},
 				),
 			},
@@ -835,6 +1761,46 @@ func main() {
 				),
 			},
 		},
+		&cli.Handler{
+			Pattern:     "[global] auth login [options]",
+			Description: "Authenticate and save a new token",
+			Callback:    loginHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+				cli.NewFlagGroup("options",
+					cli.BoolFlag{
+						Name:        "device",
+						Patterns:    []string{"--device"},
+						Description: "Authenticate by entering a code on another device, for hosts without a browser",
+						OmitValue:   true,
+					},
+				),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] auth logout",
+			Description: "Remove saved token without revoking it",
+			Callback:    logoutHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] auth revoke",
+			Description: "Revoke saved token and remove it",
+			Callback:    revokeHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] auth status",
+			Description: "Show account, scopes and source of the active token",
+			Callback:    authStatusHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
 		&cli.Handler{
 			Pattern:     "[global] about [options]",
 			Description: "Google drive metadata, quota usage",
@@ -867,6 +1833,22 @@ func main() {
 				cli.NewFlagGroup("global", globalFlags...),
 			},
 		},
+		&cli.Handler{
+			Pattern:     "[global] config show",
+			Description: "Show configuration profile",
+			Callback:    showConfigHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
+		&cli.Handler{
+			Pattern:     "[global] config set <key> <value>",
+			Description: "Set configuration profile value, use an empty value to unset",
+			Callback:    setConfigHandler,
+			FlagGroups: cli.FlagGroups{
+				cli.NewFlagGroup("global", globalFlags...),
+			},
+		},
 		&cli.Handler{
 			Pattern:     "version",
 			Description: "Print application version",
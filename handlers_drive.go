@@ -1,35 +1,49 @@
 package main
 
 import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/nanometrics/godrive/auth"
+	"github.com/nanometrics/godrive/cache"
 	"github.com/nanometrics/godrive/cli"
 	"github.com/nanometrics/godrive/drive"
+	"golang.org/x/oauth2"
 )
 
 const ClientId = "367116221053-7n0vf5akeru7on6o2fjinrecpdoe99eg.apps.googleusercontent.com"
 const ClientSecret = "1qsNodXNaWq1mQuBjUjmvhoO"
+const ClientSecretFilename = "client_secret.json"
 const TokenFilename = "token_v2.json"
-const DefaultCacheFileName = "file_cache.json"
+const EncryptedTokenFilename = "token_v2.enc"
+const DefaultCacheFileName = "cache.db"
+const LegacyCacheFileName = "file_cache.json"
 
 func listHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).List(drive.ListFilesArgs{
-		Out:         os.Stdout,
-		MaxFiles:    args.Int64("maxFiles"),
-		NameWidth:   args.Int64("nameWidth"),
-		Query:       args.String("query"),
-		SortOrder:   args.String("sortOrder"),
-		SkipHeader:  args.Bool("skipHeader"),
-		SizeInBytes: args.Bool("sizeInBytes"),
-		AbsPath:     args.Bool("absPath"),
+	err := runAsSubjects(args, func(d *drive.Drive, out io.Writer) error {
+		return d.List(drive.ListFilesArgs{
+			Out:         out,
+			MaxFiles:    args.Int64("maxFiles"),
+			NameWidth:   args.Int64("nameWidth"),
+			Query:       args.String("query"),
+			SortOrder:   args.String("sortOrder"),
+			SkipHeader:  args.Bool("skipHeader"),
+			SizeInBytes: args.Bool("sizeInBytes"),
+			AbsPath:     args.Bool("absPath"),
+		})
 	})
 	checkErr(err)
 }
@@ -59,6 +73,9 @@ func listChangesHandler(ctx cli.Context) {
 func downloadHandler(ctx cli.Context) {
 	args := ctx.Args()
 	checkDownloadArgs(args)
+	if args.Bool("delete") {
+		checkWriteScope(args)
+	}
 	err := newDrive(args).Download(drive.DownloadArgs{
 		Out:       os.Stdout,
 		Id:        args.String("fileId"),
@@ -90,8 +107,8 @@ func downloadQueryHandler(ctx cli.Context) {
 
 func downloadSyncHandler(ctx cli.Context) {
 	args := ctx.Args()
-	cachePath := filepath.Join(args.String("configDir"), DefaultCacheFileName)
-	err := newDrive(args).DownloadSync(drive.DownloadSyncArgs{
+	fileCache := openCache(args)
+	err := newCachedDrive(args, fileCache).DownloadSync(drive.DownloadSyncArgs{
 		Out:              os.Stdout,
 		Progress:         progressWriter(args.Bool("noProgress")),
 		Path:             args.String("path"),
@@ -100,8 +117,10 @@ func downloadSyncHandler(ctx cli.Context) {
 		DeleteExtraneous: args.Bool("deleteExtraneous"),
 		Timeout:          durationInSeconds(args.Int64("timeout")),
 		Resolution:       conflictResolution(args),
-		Comparer:         NewCachedMd5Comparer(cachePath),
+		Comparer:         NewCachedChecksumComparer(fileCache, hashAlgorithm(args.String("hash"))),
+		ForceUnlock:      args.Bool("forceUnlock"),
 	})
+	closeCache(fileCache, args.String("path"))
 	checkErr(err)
 }
 
@@ -132,11 +151,13 @@ func uploadHandler(ctx cli.Context) {
 		Parents:     args.StringSlice("parent"),
 		Folder:      args.String("folder"),
 		Mime:        args.String("mime"),
+		Properties:  keyValueMap(args.StringSlice("property"), "property"),
 		Recursive:   args.Bool("recursive"),
 		Share:       args.Bool("share"),
 		Delete:      args.Bool("delete"),
 		ChunkSize:   args.Int64("chunksize"),
 		Timeout:     durationInSeconds(args.Int64("timeout")),
+		Hash:        hashAlgorithm(args.String("hash")),
 	})
 	checkErr(err)
 }
@@ -150,6 +171,7 @@ func uploadStdinHandler(ctx cli.Context) {
 		Description: args.String("description"),
 		Parents:     args.StringSlice("parent"),
 		Mime:        args.String("mime"),
+		Properties:  keyValueMap(args.StringSlice("property"), "property"),
 		Share:       args.Bool("share"),
 		ChunkSize:   args.Int64("chunksize"),
 		Timeout:     durationInSeconds(args.Int64("timeout")),
@@ -160,8 +182,8 @@ func uploadStdinHandler(ctx cli.Context) {
 
 func uploadSyncHandler(ctx cli.Context) {
 	args := ctx.Args()
-	cachePath := filepath.Join(args.String("configDir"), DefaultCacheFileName)
-	err := newDrive(args).UploadSync(drive.UploadSyncArgs{
+	fileCache := openCache(args)
+	err := newCachedDrive(args, fileCache).UploadSync(drive.UploadSyncArgs{
 		Out:              os.Stdout,
 		Progress:         progressWriter(args.Bool("noProgress")),
 		Path:             args.String("path"),
@@ -171,8 +193,177 @@ func uploadSyncHandler(ctx cli.Context) {
 		ChunkSize:        args.Int64("chunksize"),
 		Timeout:          durationInSeconds(args.Int64("timeout")),
 		Resolution:       conflictResolution(args),
-		Comparer:         NewCachedMd5Comparer(cachePath),
+		Comparer:         NewCachedChecksumComparer(fileCache, hashAlgorithm(args.String("hash"))),
+		ForceUnlock:      args.Bool("forceUnlock"),
+	})
+	closeCache(fileCache, args.String("path"))
+	checkErr(err)
+}
+
+func restoreSyncHandler(ctx cli.Context) {
+	args := ctx.Args()
+	fileCache := openCache(args)
+	if args.String("at") == "" {
+		ExitF("--at is required")
+	}
+	err := newCachedDrive(args, fileCache).RestoreSync(drive.RestoreSyncArgs{
+		Out:      os.Stdout,
+		Progress: progressWriter(args.Bool("noProgress")),
+		RootId:   args.String("fileId"),
+		Path:     args.String("path"),
+		At:       parseTime(args.String("at")),
+		DryRun:   args.Bool("dryRun"),
+		Force:    args.Bool("force"),
+		Timeout:  durationInSeconds(args.Int64("timeout")),
+	})
+	closeCache(fileCache, "")
+	checkErr(err)
+}
+
+func verifySyncHandler(ctx cli.Context) {
+	args := ctx.Args()
+	fileCache := openCache(args)
+	err := newCachedDrive(args, fileCache).VerifySync(drive.VerifySyncArgs{
+		Out:      os.Stdout,
+		Path:     args.String("path"),
+		RootId:   args.String("fileId"),
+		Comparer: NewCachedChecksumComparer(fileCache, hashAlgorithm(args.String("hash"))),
+	})
+	closeCache(fileCache, args.String("path"))
+	checkErr(err)
+}
+
+func repairSyncHandler(ctx cli.Context) {
+	args := ctx.Args()
+
+	// Showing the fixes works with a read-only scope
+	if args.Bool("apply") {
+		checkWriteScope(args)
+	}
+
+	fileCache := openCache(args)
+	err := newCachedDrive(args, fileCache).RepairSync(drive.RepairSyncArgs{
+		Out:         os.Stdout,
+		RootId:      args.String("fileId"),
+		TrashNewer:  args.Bool("trashNewer"),
+		TrashDirs:   args.Bool("trashDirs"),
+		DryRun:      !args.Bool("apply"),
+		ForceUnlock: args.Bool("forceUnlock"),
+	})
+	closeCache(fileCache, "")
+	checkErr(err)
+}
+
+func adoptSyncHandler(ctx cli.Context) {
+	args := ctx.Args()
+	fileCache := openCache(args)
+	err := newDrive(args).AdoptSync(drive.AdoptSyncArgs{
+		Out:         os.Stdout,
+		Path:        args.String("path"),
+		RootId:      args.String("fileId"),
+		Comparer:    NewCachedChecksumComparer(fileCache, hashAlgorithm(args.String("hash"))),
+		DryRun:      args.Bool("dryRun"),
+		ForceUnlock: args.Bool("forceUnlock"),
+	})
+	closeCache(fileCache, args.String("path"))
+	checkErr(err)
+}
+
+func detachSyncHandler(ctx cli.Context) {
+	args := ctx.Args()
+	fileCache := openCache(args)
+	err := newCachedDrive(args, fileCache).DetachSync(drive.DetachSyncArgs{
+		Out:         os.Stdout,
+		RootId:      args.String("fileId"),
+		DryRun:      args.Bool("dryRun"),
+		ForceUnlock: args.Bool("forceUnlock"),
+	})
+	closeCache(fileCache, "")
+	checkErr(err)
+}
+
+func runSyncHandler(ctx cli.Context) {
+	args := ctx.Args()
+	configDir := getConfigDir(args)
+
+	jobsPath := args.String("jobsFile")
+	if jobsPath == "" {
+		jobsPath = ConfigFilePath(configDir, JobsFilename)
+	}
+
+	jobsFile, err := readJobs(jobsPath)
+	checkErr(err)
+
+	config, err := readConfig(ConfigFilePath(configDir, ConfigFilename))
+	checkErr(err)
+
+	profile, found := config.Profiles[selectedProfileName(config, args.String("profile"))]
+	if !found {
+		profile = &Profile{}
+	}
+	checkErr(jobsFile.prepare(profile))
+
+	jobs, err := jobsFile.selectJobs(args.StringSlice("job"))
+	checkErr(err)
+
+	// Download jobs only need read access, a read-only profile can run them
+	for _, job := range jobs {
+		if job.Direction == JobUpload {
+			checkWriteScope(args)
+			break
+		}
+	}
+
+	fileCache := openCache(args)
+	err = runJobs(runJobsArgs{
+		out:         os.Stdout,
+		progress:    progressWriter(args.Bool("noProgress")),
+		drive:       newCachedDrive(args, fileCache),
+		cache:       fileCache,
+		jobs:        jobs,
+		stateDir:    filepath.Join(configDir, JobsStateDirName),
+		parallelism: int(args.Int64("parallel")),
+		force:       args.Bool("force"),
+		forceUnlock: args.Bool("forceUnlock"),
+		dryRun:      args.Bool("dryRun"),
+		chunkSize:   args.Int64("chunksize"),
+		timeout:     durationInSeconds(args.Int64("timeout")),
 	})
+	closeCache(fileCache, "")
+	checkErr(err)
+}
+
+func hashHandler(ctx cli.Context) {
+	args := ctx.Args()
+
+	hashArgs := drive.HashArgs{
+		Out:        os.Stdout,
+		SkipHeader: args.Bool("skipHeader"),
+	}
+
+	if _, ok := args["target"]; ok {
+		// Treat target as a file id if there is no local file with that name
+		if _, err := os.Stat(args.String("target")); err == nil {
+			hashArgs.Path = args.String("target")
+		} else {
+			hashArgs.Id = args.String("target")
+		}
+	} else {
+		hashArgs.Path = args.String("path")
+		hashArgs.Id = args.String("fileId")
+	}
+
+	for _, name := range args.StringSlice("algorithm") {
+		hashArgs.Algorithms = append(hashArgs.Algorithms, hashAlgorithm(name))
+	}
+
+	// Hashing a local path must not ask for authentication
+	var d *drive.Drive
+	if hashArgs.Id != "" {
+		d = newDrive(args)
+	}
+
+	err := d.Hash(hashArgs)
 	checkErr(err)
 }
 
@@ -206,11 +397,14 @@ func infoHandler(ctx cli.Context) {
 func importHandler(ctx cli.Context) {
 	args := ctx.Args()
 	err := newDrive(args).Import(drive.ImportArgs{
-		Mime:     args.String("mime"),
-		Out:      os.Stdout,
-		Path:     args.String("path"),
-		Parents:  args.StringSlice("parent"),
-		Progress: progressWriter(args.Bool("noProgress")),
+		Mime:            args.String("mime"),
+		Out:             os.Stdout,
+		Path:            args.String("path"),
+		Parents:         args.StringSlice("parent"),
+		Progress:        progressWriter(args.Bool("noProgress")),
+		Recursive:       args.Bool("recursive"),
+		SkipUnsupported: args.Bool("skipUnsupported"),
+		Conversions:     importConversions(args),
 	})
 	checkErr(err)
 }
@@ -239,6 +433,30 @@ func listRevisionsHandler(ctx cli.Context) {
 	checkErr(err)
 }
 
+func getMetaHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).GetMeta(drive.GetMetaArgs{
+		Out: os.Stdout,
+		Id:  args.String("fileId"),
+	})
+	checkErr(err)
+}
+
+func setMetaHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).SetMeta(drive.SetMetaArgs{
+		Out:           os.Stdout,
+		Id:            args.String("fileId"),
+		Description:   args.String("description"),
+		Mime:          args.String("mime"),
+		Properties:    keyValueMap(args.StringSlice("property"), "property"),
+		AppProperties: keyValueMap(args.StringSlice("appProperty"), "app property"),
+		Star:          args.Bool("star"),
+		Unstar:        args.Bool("unstar"),
+	})
+	checkErr(err)
+}
+
 func mkdirHandler(ctx cli.Context) {
 	args := ctx.Args()
 	err := newDrive(args).Mkdir(drive.MkdirArgs{
@@ -246,6 +464,7 @@ func mkdirHandler(ctx cli.Context) {
 		Name:        args.String("name"),
 		Description: args.String("description"),
 		Parents:     args.StringSlice("parent"),
+		Properties:  keyValueMap(args.StringSlice("property"), "property"),
 	})
 	checkErr(err)
 }
@@ -253,13 +472,31 @@ func mkdirHandler(ctx cli.Context) {
 func shareHandler(ctx cli.Context) {
 	args := ctx.Args()
 	err := newDrive(args).Share(drive.ShareArgs{
-		Out:          os.Stdout,
-		FileId:       args.String("fileId"),
-		Role:         args.String("role"),
-		Type:         args.String("type"),
-		Email:        args.String("email"),
-		Domain:       args.String("domain"),
-		Discoverable: args.Bool("discoverable"),
+		Out:               os.Stdout,
+		FileId:            args.String("fileId"),
+		Role:              args.String("role"),
+		Type:              args.String("type"),
+		Email:             args.String("email"),
+		Domain:            args.String("domain"),
+		Discoverable:      args.Bool("discoverable"),
+		Expiration:        parseExpiration(args.String("expires")),
+		NoNotify:          args.Bool("noNotify"),
+		Message:           args.String("message"),
+		TransferOwnership: args.Bool("transferOwnership"),
+	})
+	checkErr(err)
+}
+
+func shareUpdateHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).UpdatePermission(drive.UpdatePermissionArgs{
+		Out:               os.Stdout,
+		FileId:            args.String("fileId"),
+		PermissionId:      args.String("permissionId"),
+		Role:              args.String("role"),
+		Expiration:        parseExpiration(args.String("expires")),
+		RemoveExpiration:  args.Bool("removeExpiration"),
+		TransferOwnership: args.Bool("transferOwnership"),
 	})
 	checkErr(err)
 }
@@ -283,6 +520,69 @@ func shareRevokeHandler(ctx cli.Context) {
 	checkErr(err)
 }
 
+func shareAuditHandler(ctx cli.Context) {
+	args := ctx.Args()
+
+	// Default to the root of My Drive when no directory is given
+	id := "root"
+	if _, ok := args["folderId"]; ok {
+		id = args.String("folderId")
+	}
+
+	err := runAsSubjects(args, func(d *drive.Drive, out io.Writer) error {
+		return d.ShareAudit(drive.ShareAuditArgs{
+			Out:        out,
+			Id:         id,
+			Recursive:  args.Bool("recursive"),
+			Filter:     permissionFilter(args),
+			SkipHeader: args.Bool("skipHeader"),
+		})
+	})
+	checkErr(err)
+}
+
+func shareRevokeAllHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).RevokePermissions(drive.RevokePermissionsArgs{
+		Out:       os.Stdout,
+		Id:        args.String("fileId"),
+		Filter:    permissionFilter(args),
+		Recursive: args.Bool("recursive"),
+		DryRun:    args.Bool("dryRun"),
+		Rate:      args.Int64("rate"),
+	})
+	checkErr(err)
+}
+
+func shareGrantHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).GrantPermissions(drive.GrantPermissionsArgs{
+		Out:          os.Stdout,
+		Id:           args.String("fileId"),
+		Role:         args.String("role"),
+		Type:         args.String("type"),
+		Email:        args.String("email"),
+		Domain:       args.String("domain"),
+		Discoverable: args.Bool("discoverable"),
+		Expiration:   parseExpiration(args.String("expires")),
+		NoNotify:     args.Bool("noNotify"),
+		Message:      args.String("message"),
+		Recursive:    args.Bool("recursive"),
+		DryRun:       args.Bool("dryRun"),
+		Rate:         args.Int64("rate"),
+	})
+	checkErr(err)
+}
+
+func permissionFilter(args cli.Arguments) drive.PermissionFilter {
+	return drive.PermissionFilter{
+		Type:   args.String("type"),
+		Role:   args.String("role"),
+		Email:  args.String("email"),
+		Domain: args.String("domain"),
+	}
+}
+
 func deleteHandler(ctx cli.Context) {
 	args := ctx.Args()
 	err := newDrive(args).Delete(drive.DeleteArgs{
@@ -293,6 +593,50 @@ func deleteHandler(ctx cli.Context) {
 	checkErr(err)
 }
 
+func dedupeHandler(ctx cli.Context) {
+	args := ctx.Args()
+	keep := duplicateKeep(args)
+
+	// Only trashing changes the drive, listing works with a read-only scope
+	if args.Bool("trash") {
+		if keep == drive.KeepNone {
+			ExitF("--trash requires --keep oldest or --keep newest")
+		}
+		checkWriteScope(args)
+	}
+
+	err := newDrive(args).Dedupe(drive.DedupeArgs{
+		Out:         os.Stdout,
+		Query:       args.String("query"),
+		By:          duplicateKey(args),
+		Keep:        keep,
+		Trash:       args.Bool("trash"),
+		SizeInBytes: args.Bool("sizeInBytes"),
+	})
+	checkErr(err)
+}
+
+func diskUsageHandler(ctx cli.Context) {
+	args := ctx.Args()
+
+	// Default to the root of My Drive when no directory is given
+	id := "root"
+	if _, ok := args["fileId"]; ok {
+		id = args.String("fileId")
+	}
+
+	err := runAsSubjects(args, func(d *drive.Drive, out io.Writer) error {
+		return d.DiskUsage(drive.DiskUsageArgs{
+			Out:         out,
+			Id:          id,
+			Depth:       args.Int64("depth"),
+			Top:         args.Int64("top"),
+			SizeInBytes: args.Bool("sizeInBytes"),
+		})
+	})
+	checkErr(err)
+}
+
 func listSyncHandler(ctx cli.Context) {
 	args := ctx.Args()
 	err := newDrive(args).ListSync(drive.ListSyncArgs{
@@ -304,7 +648,8 @@ func listSyncHandler(ctx cli.Context) {
 
 func listRecursiveSyncHandler(ctx cli.Context) {
 	args := ctx.Args()
-	err := newDrive(args).ListRecursiveSync(drive.ListRecursiveSyncArgs{
+	fileCache := openCache(args)
+	err := newCachedDrive(args, fileCache).ListRecursiveSync(drive.ListRecursiveSyncArgs{
 		Out:         os.Stdout,
 		RootId:      args.String("fileId"),
 		SkipHeader:  args.Bool("skipHeader"),
@@ -312,6 +657,7 @@ func listRecursiveSyncHandler(ctx cli.Context) {
 		SizeInBytes: args.Bool("sizeInBytes"),
 		SortOrder:   args.String("sortOrder"),
 	})
+	closeCache(fileCache, "")
 	checkErr(err)
 }
 
@@ -325,6 +671,46 @@ func deleteRevisionHandler(ctx cli.Context) {
 	checkErr(err)
 }
 
+func keepRevisionHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).KeepRevision(drive.KeepRevisionArgs{
+		Out:        os.Stdout,
+		FileId:     args.String("fileId"),
+		RevisionId: args.String("revId"),
+		Unset:      args.Bool("unset"),
+		Recursive:  args.Bool("recursive"),
+	})
+	checkErr(err)
+}
+
+func restoreRevisionHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).RestoreRevision(drive.RestoreRevisionArgs{
+		Out:        os.Stdout,
+		Progress:   progressWriter(args.Bool("noProgress")),
+		FileId:     args.String("fileId"),
+		RevisionId: args.String("revId"),
+		Recursive:  args.Bool("recursive"),
+		ChunkSize:  args.Int64("chunksize"),
+		Timeout:    durationInSeconds(args.Int64("timeout")),
+	})
+	checkErr(err)
+}
+
+func pruneRevisionsHandler(ctx cli.Context) {
+	args := ctx.Args()
+	err := newDrive(args).PruneRevisions(drive.PruneRevisionsArgs{
+		Out:         os.Stdout,
+		FileId:      args.String("fileId"),
+		KeepLast:    args.Int64("keepLast"),
+		OlderThan:   parseAge(args.String("olderThan")),
+		Recursive:   args.Bool("recursive"),
+		DryRun:      args.Bool("dryRun"),
+		SizeInBytes: args.Bool("sizeInBytes"),
+	})
+	checkErr(err)
+}
+
 func aboutHandler(ctx cli.Context) {
 	args := ctx.Args()
 	err := newDrive(args).About(drive.AboutArgs{
@@ -351,31 +737,440 @@ func aboutExportHandler(ctx cli.Context) {
 }
 
 func getOauthClient(args cli.Arguments) (*http.Client, error) {
+	tokenSource, _, err := getTokenSource(args)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(oauth2.NoContext, tokenSource), nil
+}
+
+// The token source is created once per run, so a login or
+// a passphrase prompt is not repeated when it is needed again
+var runTokenSource struct {
+	source      oauth2.TokenSource
+	description string
+}
+
+// Returns the token source selected by the global flags
+// and a description of where the token comes from
+func getTokenSource(args cli.Arguments) (oauth2.TokenSource, string, error) {
+	if runTokenSource.source == nil {
+		source, description, err := newTokenSource(args)
+		if err != nil {
+			return nil, "", err
+		}
+		runTokenSource.source = source
+		runTokenSource.description = description
+	}
+	return runTokenSource.source, runTokenSource.description, nil
+}
+
+func newTokenSource(args cli.Arguments) (oauth2.TokenSource, string, error) {
 	if args.String("refreshToken") != "" && args.String("accessToken") != "" {
 		ExitF("Access token not needed when refresh token is provided")
 	}
 
+	clientId, clientSecret := oauthClientCredentials(args)
+
 	if args.String("refreshToken") != "" {
-		return auth.NewRefreshTokenClient(ClientId, ClientSecret, args.String("refreshToken")), nil
+		return auth.NewRefreshTokenSource(clientId, clientSecret, args.String("refreshToken")), "refresh token", nil
 	}
 
 	if args.String("accessToken") != "" {
-		return auth.NewAccessTokenClient(ClientId, ClientSecret, args.String("accessToken")), nil
+		return auth.NewAccessTokenSource(clientId, clientSecret, args.String("accessToken")), "access token", nil
 	}
 
 	configDir := getConfigDir(args)
 
+	if args.Bool("defaultCredentials") {
+		if args.String("serviceAccount") != "" {
+			ExitF("--default-credentials can not be used with --service-account")
+		}
+
+		credentials, err := auth.FindDefaultCredentials(oauthScope(args), args.String("subject"))
+		if err != nil {
+			return nil, "", err
+		}
+		return credentials.TokenSource, fmt.Sprintf("application default credentials %s", credentials.Source), nil
+	}
+
 	if args.String("serviceAccount") != "" {
 		serviceAccountPath := ConfigFilePath(configDir, args.String("serviceAccount"))
-		serviceAccountClient, err := auth.NewServiceAccountClient(serviceAccountPath, args.String("subject"))
+		tokenSource, err := auth.NewServiceAccountTokenSource(serviceAccountPath, args.String("subject"), oauthScope(args))
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
-		return serviceAccountClient, nil
+		return tokenSource, fmt.Sprintf("service account %s", serviceAccountPath), nil
 	}
 
-	tokenPath := ConfigFilePath(configDir, TokenFilename)
-	return auth.NewFileSourceClient(ClientId, ClientSecret, tokenPath, authCodePrompt)
+	return storedTokenSource(args, tokenStore(args))
+}
+
+func storedTokenSource(args cli.Arguments, store auth.TokenStore) (oauth2.TokenSource, string, error) {
+	clientId, clientSecret := oauthClientCredentials(args)
+	tokenSource, err := auth.NewStoredTokenSource(clientId, clientSecret, oauthScope(args), store, loginPrompt())
+	if err != nil {
+		return nil, "", err
+	}
+	return tokenSource, fmt.Sprintf("token in %s", store), nil
+}
+
+// Returns the oauth scope selected by the profile or --scope
+func oauthScope(args cli.Arguments) string {
+	switch args.String("scope") {
+	case "", ScopeFull:
+		return auth.ScopeFull
+	case ScopeReadonly:
+		return auth.ScopeReadonly
+	case ScopeFile:
+		return auth.ScopeFile
+	}
+
+	ExitF("Unknown scope '%s', must be %s, %s or %s", args.String("scope"), ScopeReadonly, ScopeFile, ScopeFull)
+	return ""
+}
+
+// Tokens are kept per scope, the full scope has no suffix so
+// tokens saved before scopes could be selected are still found
+func tokenScopeSuffix(args cli.Arguments) string {
+	if oauthScope(args) == auth.ScopeFull {
+		return ""
+	}
+	return "_" + args.String("scope")
+}
+
+func scopedTokenFilename(name string, args cli.Arguments) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + tokenScopeSuffix(args) + ext
+}
+
+// Wraps the handlers of commands that change the drive, they fail before
+// doing anything when the token is not allowed to change the drive
+func writeCommand(callback func(cli.Context)) func(cli.Context) {
+	return func(ctx cli.Context) {
+		checkWriteScope(ctx.Args())
+		callback(ctx)
+	}
+}
+
+// The selected scope is checked first so a read-only profile fails without
+// any requests. Tokens keep the scopes they were granted, which may differ
+// from the selected scope, so the scopes of the token are checked as well
+func checkWriteScope(args cli.Arguments) {
+	if args.String("scope") == ScopeReadonly {
+		ExitF("This command changes the drive and needs write access, but the %s scope is selected. Use --scope %s or --scope %s", ScopeReadonly, ScopeFile, ScopeFull)
+	}
+
+	tokenSource, _, err := getTokenSource(args)
+	if err != nil {
+		ExitF("Failed getting oauth client: %s", err)
+	}
+
+	token, err := tokenSource.Token()
+	if err != nil {
+		ExitF("Failed to get token: %s", err)
+	}
+
+	// Stored tokens record their scopes, others are looked up. Drive
+	// rejects the changes anyway, the check only makes the command fail early
+	scopes := auth.StoredScopes(tokenSource)
+	if scopes == nil {
+		info, err := auth.GetTokenInfo(token.AccessToken)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not check the scopes of the token: %s\n", err)
+			return
+		}
+		scopes = info.Scopes
+	}
+
+	if !auth.CanWrite(scopes) {
+		ExitF("This command changes the drive and needs write access, but the token only has the scopes %s. Log in again with --scope %s or --scope %s", strings.Join(scopes, ", "), ScopeFile, ScopeFull)
+	}
+}
+
+// Returns the token store selected by the profile or --token-store. A plain
+// token file left from before the store was selected is moved into the store
+func tokenStore(args cli.Arguments) auth.TokenStore {
+	configDir := getConfigDir(args)
+	fileStore := auth.FileStore{Path: ConfigFilePath(configDir, scopedTokenFilename(TokenFilename, args))}
+
+	var store auth.TokenStore
+
+	switch name := args.String("tokenStore"); name {
+	case "", TokenStoreFile:
+		return fileStore
+	case TokenStoreKeyring:
+		account, err := filepath.Abs(configDir)
+		if err != nil {
+			ExitF("Failed to get absolute path of %s: %s", configDir, err)
+		}
+		store = auth.KeyringStore{Account: account + tokenScopeSuffix(args)}
+	case TokenStoreEncrypted:
+		store = &auth.EncryptedFileStore{
+			Path:       ConfigFilePath(configDir, scopedTokenFilename(EncryptedTokenFilename, args)),
+			Key:        tokenKey(),
+			Passphrase: readTokenPassphrase,
+		}
+	default:
+		ExitF("%s", validateTokenStore(name))
+	}
+
+	moved, err := auth.MigrateToken(fileStore, store)
+	if err != nil {
+		ExitF("Failed to move token from %s to %s: %s", fileStore, store, err)
+	}
+	if moved {
+		fmt.Fprintf(os.Stderr, "Moved token from %s to %s\n", fileStore, store)
+	}
+
+	return store
+}
+
+// Key for the encrypted token file given as base64 in GODRIVE_TOKEN_KEY
+func tokenKey() []byte {
+	value := os.Getenv("GODRIVE_TOKEN_KEY")
+	if value == "" {
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != auth.KeySize {
+		ExitF("GODRIVE_TOKEN_KEY must be %d bytes encoded as base64", auth.KeySize)
+	}
+	return key
+}
+
+// Reads the passphrase of the encrypted token file from GODRIVE_TOKEN_PASSPHRASE,
+// or from the terminal, confirm is set when the file is created
+func readTokenPassphrase(confirm bool) (string, error) {
+	if value := os.Getenv("GODRIVE_TOKEN_PASSPHRASE"); value != "" {
+		return value, nil
+	}
+
+	if stat, err := os.Stdin.Stat(); err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return "", fmt.Errorf("No terminal to read the token passphrase from, set GODRIVE_TOKEN_PASSPHRASE or GODRIVE_TOKEN_KEY")
+	}
+
+	passphrase, err := readHidden("Token passphrase: ")
+	if err != nil || !confirm {
+		return passphrase, err
+	}
+
+	repeated, err := readHidden("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if repeated != passphrase {
+		return "", fmt.Errorf("Passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// Reads a line from the terminal without echo, echo is
+// left on where stty is not available
+func readHidden(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	if setEcho(false) {
+		defer setEcho(true)
+	}
+	defer fmt.Fprintln(os.Stderr)
+
+	line, err := readLine(nil)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("Failed reading passphrase: %s", err)
+	}
+	return line, nil
+}
+
+type stdinLine struct {
+	line string
+	err  error
+}
+
+// Lines from stdin are read by a single goroutine. A read that is canceled
+// leaves the next line to the next reader, so a prompt that is no longer
+// needed does not take the input meant for a later one
+var stdinLines = make(chan stdinLine)
+var stdinReader sync.Once
+
+var errReadCanceled = fmt.Errorf("Read canceled")
+
+// Reads a line from stdin without the line ending, or
+// fails with errReadCanceled when cancel is closed first
+func readLine(cancel <-chan struct{}) (string, error) {
+	stdinReader.Do(func() {
+		go func() {
+			r := bufio.NewReader(os.Stdin)
+			for {
+				line, err := r.ReadString('\n')
+				if err == io.EOF && line != "" {
+					err = nil
+				}
+				stdinLines <- stdinLine{strings.TrimRight(line, "\r\n"), err}
+				if err != nil {
+					close(stdinLines)
+					return
+				}
+			}
+		}()
+	})
+
+	select {
+	case l, ok := <-stdinLines:
+		if !ok {
+			return "", io.EOF
+		}
+		return l.line, l.err
+	case <-cancel:
+		return "", errReadCanceled
+	}
+}
+
+func setEcho(on bool) bool {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run() == nil
+}
+
+// Returns the oauth client given by flags, environment variables or
+// client secret file in the config dir, falls back to the built-in client
+func oauthClientCredentials(args cli.Arguments) (string, string) {
+	if args.String("clientId") != "" {
+		return args.String("clientId"), args.String("clientSecret")
+	}
+
+	if args.String("clientSecret") != "" {
+		ExitF("--client-secret requires --client-id")
+	}
+
+	if os.Getenv("GODRIVE_CLIENT_ID") != "" {
+		return os.Getenv("GODRIVE_CLIENT_ID"), os.Getenv("GODRIVE_CLIENT_SECRET")
+	}
+
+	path := ConfigFilePath(getConfigDir(args), ClientSecretFilename)
+	clientId, clientSecret, exists, err := auth.ReadClientSecretFile(path)
+	if err != nil {
+		ExitF("%s", err)
+	}
+	if exists {
+		return clientId, clientSecret
+	}
+
+	return ClientId, ClientSecret
+}
+
+func loginHandler(ctx cli.Context) {
+	args := ctx.Args()
+	store := tokenStore(args)
+
+	prompt := loginPrompt()
+	prompt.Device = args.Bool("device")
+
+	clientId, clientSecret := oauthClientCredentials(args)
+	checkErr(auth.Login(clientId, clientSecret, oauthScope(args), store, prompt))
+	fmt.Printf("Saved token to %s\n", store)
+}
+
+func logoutHandler(ctx cli.Context) {
+	args := ctx.Args()
+	store := tokenStore(args)
+
+	if _, exists, _ := store.Load(); !exists {
+		fmt.Println("Not logged in")
+		return
+	}
+
+	checkErr(store.Delete())
+	fmt.Printf("Removed token from %s, the token is not revoked, use 'godrive auth revoke' to revoke it\n", store)
+}
+
+func revokeHandler(ctx cli.Context) {
+	args := ctx.Args()
+	store := tokenStore(args)
+
+	token, exists, err := auth.ReadToken(store)
+	checkErr(err)
+	if !exists {
+		fmt.Println("Not logged in")
+		return
+	}
+
+	// Revoking the refresh token also revokes its access tokens
+	value := token.RefreshToken
+	if value == "" {
+		value = token.AccessToken
+	}
+
+	err = auth.RevokeToken(value)
+	if err == auth.ErrInvalidToken {
+		fmt.Println("Token was already expired or revoked")
+	} else {
+		checkErr(err)
+		fmt.Println("Revoked token")
+	}
+
+	checkErr(store.Delete())
+	fmt.Printf("Removed token from %s\n", store)
+}
+
+func authStatusHandler(ctx cli.Context) {
+	args := ctx.Args()
+
+	// Status should not start a login when there is no token
+	usesTokenFile := args.String("refreshToken") == "" && args.String("accessToken") == "" && args.String("serviceAccount") == "" && !args.Bool("defaultCredentials")
+	var tokenSource oauth2.TokenSource
+	var source string
+	var err error
+
+	if usesTokenFile {
+		store := tokenStore(args)
+		_, exists, loadErr := store.Load()
+		checkErr(loadErr)
+		if !exists {
+			fmt.Println("Not logged in, use 'godrive auth login' to log in")
+			return
+		}
+		tokenSource, source, err = storedTokenSource(args, store)
+	} else {
+		tokenSource, source, err = getTokenSource(args)
+	}
+	checkErr(err)
+
+	token, err := tokenSource.Token()
+	if err != nil {
+		ExitF("Failed to get token: %s", err)
+	}
+
+	info, err := auth.GetTokenInfo(token.AccessToken)
+	checkErr(err)
+
+	client, err := drive.New(oauth2.NewClient(oauth2.NoContext, tokenSource))
+	checkErr(err)
+
+	email, err := client.UserEmail()
+	checkErr(err)
+
+	expiry := token.Expiry
+	if expiry.IsZero() {
+		expiry = info.Expiry
+	}
+
+	fmt.Printf("Account: %s\n", email)
+	fmt.Printf("Source: %s\n", source)
+	if args.String("serviceAccount") == "" && !args.Bool("defaultCredentials") {
+		clientId, _ := oauthClientCredentials(args)
+		fmt.Printf("Client: %s\n", clientId)
+	}
+	fmt.Printf("Scopes: %s\n", strings.Join(info.Scopes, ", "))
+	if !expiry.IsZero() {
+		fmt.Printf("Access token expires: %s\n", expiry.Local().Format(time.RFC3339))
+	}
 }
 
 func getConfigDir(args cli.Arguments) string {
@@ -400,18 +1195,157 @@ func newDrive(args cli.Arguments) *drive.Drive {
 	return client
 }
 
-func authCodePrompt(url string) func() string {
-	return func() string {
+// Opens the cache in the config directory, commands
+// run without a cache if it can not be opened
+func openCache(args cli.Arguments) *cache.Cache {
+	configDir := getConfigDir(args)
+
+	c, err := cache.Open(filepath.Join(configDir, DefaultCacheFileName))
+	if err == cache.ErrInUse {
+		fmt.Fprintf(os.Stderr, "%s, running without cache. Checksums of local files are calculated again\n", err)
+		return nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Running without cache: %s\n", err)
+		return nil
+	}
+
+	migrateLegacyCache(c, filepath.Join(configDir, LegacyCacheFileName))
+	return c
+}
+
+// Checksums of the json file cache used before the cache database
+type legacyCacheInfo struct {
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified"`
+	Md5      string `json:"md5"`
+}
+
+// Moves the md5 checksums of the legacy json cache into the cache
+// database. The file is kept if it can not be read or moved
+func migrateLegacyCache(c *cache.Cache, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+
+	legacy := map[string]*legacyCacheInfo{}
+	err = json.NewDecoder(f).Decode(&legacy)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read old cache file %s, it is no longer used and can be deleted: %s\n", path, err)
+		return
+	}
+
+	for localPath, info := range legacy {
+		if info == nil || info.Md5 == "" {
+			continue
+		}
+
+		// Checksums already in the database are newer
+		if cached, err := c.File(localPath); err != nil || cached != nil {
+			continue
+		}
+
+		err := c.PutFile(localPath, &cache.FileInfo{
+			Size:      info.Size,
+			Modified:  info.Modified,
+			Checksums: map[string]string{drive.Md5.String(): info.Md5},
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to move old cache file %s to the cache database: %s\n", path, err)
+			return
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to remove old cache file %s: %s\n", path, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Moved %d cached checksums from %s to the cache database\n", len(legacy), path)
+}
+
+// Removes cached files below path that no longer exist and closes the
+// cache, call it before checkErr as that exits the process
+func closeCache(c *cache.Cache, path string) {
+	if c == nil {
+		return
+	}
+
+	if path != "" {
+		if absPath, err := filepath.Abs(path); err == nil {
+			c.PruneFiles(absPath + string(os.PathSeparator))
+		}
+	}
+
+	c.Close()
+}
+
+func newCachedDrive(args cli.Arguments, c *cache.Cache) *drive.Drive {
+	d := newDrive(args)
+	d.SetCache(c)
+	return d
+}
+
+func loginPrompt() auth.LoginPrompt {
+	prompt := auth.LoginPrompt{
+		ShowUrl: func(url string) {
+			fmt.Println("Authentication needed")
+			fmt.Println("Go to the following url in your browser:")
+			fmt.Printf("%s\n\n", url)
+			openBrowser(url)
+		},
+	}
+
+	prompt.ShowDeviceCode = func(url, code string) {
 		fmt.Println("Authentication needed")
-		fmt.Println("Go to the following url in your browser:")
-		fmt.Printf("%s\n\n", url)
-		fmt.Print("Enter verification code: ")
+		fmt.Printf("Go to %s on any device and enter the code: %s\n", url, code)
+		fmt.Println("Waiting for the login to be approved...")
+	}
+
+	// Reading the redirect is only possible when a user is at the terminal
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		prompt.ReadRedirect = func(done <-chan struct{}) (string, bool) {
+			fmt.Println("Waiting for the browser to complete authentication.")
+			fmt.Println("If the browser runs on another machine it can not reach this one,")
+			fmt.Print("paste the address it was redirected to here: ")
 
-		var code string
-		if _, err := fmt.Scan(&code); err != nil {
-			fmt.Printf("Failed reading code: %s", err.Error())
+			value, err := readLine(done)
+			if err == errReadCanceled {
+				fmt.Println()
+				return "", false
+			}
+			if err != nil {
+				fmt.Printf("Failed reading redirect address: %s\n", err)
+				return "", false
+			}
+			return value, true
 		}
-		return code
+	}
+
+	return prompt
+}
+
+// Tries to open the url in the default browser, the url
+// is also printed so failures are ignored
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		// Without a display xdg-open may start a text browser in the terminal
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return
+		}
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if cmd.Start() == nil {
+		go cmd.Wait()
 	}
 }
 
@@ -450,6 +1384,32 @@ func conflictResolution(args cli.Arguments) drive.ConflictResolution {
 	return drive.NoResolution
 }
 
+func duplicateKey(args cli.Arguments) drive.DuplicateKey {
+	switch args.String("by") {
+	case "md5":
+		return drive.DuplicateByMd5
+	case "name":
+		return drive.DuplicateByName
+	}
+
+	ExitF("Invalid value for --by, must be md5 or name")
+	return drive.DuplicateByMd5
+}
+
+func duplicateKeep(args cli.Arguments) drive.DuplicateKeep {
+	switch args.String("keep") {
+	case "":
+		return drive.KeepNone
+	case "oldest":
+		return drive.KeepOldest
+	case "newest":
+		return drive.KeepNewest
+	}
+
+	ExitF("Invalid value for --keep, must be oldest or newest")
+	return drive.KeepNone
+}
+
 func checkUploadArgs(args cli.Arguments) {
 	if args.Bool("recursive") && args.Bool("share") {
 		ExitF("--share is not allowed for recursive uploads")
@@ -461,3 +1421,40 @@ func checkDownloadArgs(args cli.Arguments) {
 		ExitF("--delete is not allowed for recursive downloads")
 	}
 }
+
+func importConversions(args cli.Arguments) map[string]string {
+	conversions := map[string]string{}
+
+	for _, c := range args.StringSlice("convert") {
+		parts := strings.SplitN(c, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			ExitF("Invalid conversion '%s', expected <extension>=<mime>", c)
+		}
+		ext := strings.ToLower(strings.TrimPrefix(parts[0], "."))
+		conversions[ext] = parts[1]
+	}
+
+	return conversions
+}
+
+func keyValueMap(values []string, name string) map[string]string {
+	m := map[string]string{}
+
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			ExitF("Invalid %s '%s', expected <key>=<value>", name, v)
+		}
+		m[parts[0]] = parts[1]
+	}
+
+	return m
+}
+
+func hashAlgorithm(name string) drive.HashAlgorithm {
+	algo, err := drive.ParseHashAlgorithm(name)
+	if err != nil {
+		ExitF("%s", err)
+	}
+	return algo
+}
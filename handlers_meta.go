@@ -94,3 +94,44 @@ func stripOptionals(pattern []string) []string {
 	}
 	return newArgs
 }
+
+func showConfigHandler(ctx cli.Context) {
+	args := ctx.Args()
+	configPath := ConfigFilePath(getConfigDir(args), ConfigFilename)
+
+	config, err := readConfig(configPath)
+	checkErr(err)
+
+	name := selectedProfileName(config, args.String("profile"))
+	p, found := config.Profiles[name]
+	if !found {
+		fmt.Printf("Profile '%s' not found in %s, using defaults\n", name, configPath)
+		p = &Profile{}
+	}
+
+	printProfile(os.Stdout, name, p.withDefaults())
+}
+
+func setConfigHandler(ctx cli.Context) {
+	args := ctx.Args()
+	configPath := ConfigFilePath(getConfigDir(args), ConfigFilename)
+
+	config, err := readConfig(configPath)
+	checkErr(err)
+
+	name := selectedProfileName(config, args.String("profile"))
+	if config.Profiles == nil {
+		config.Profiles = map[string]*Profile{}
+	}
+
+	p, found := config.Profiles[name]
+	if !found {
+		p = &Profile{}
+		config.Profiles[name] = p
+	}
+
+	checkErr(p.set(args.String("key"), args.String("value")))
+	checkErr(writeConfig(configPath, config))
+
+	fmt.Printf("Updated %s in profile '%s'\n", args.String("key"), name)
+}
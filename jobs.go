@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nanometrics/godrive/cache"
+	"github.com/nanometrics/godrive/drive"
+)
+
+const JobsFilename = "jobs.toml"
+const JobsStateDirName = "jobs"
+
+const (
+	JobUpload   = "upload"
+	JobDownload = "download"
+)
+
+type JobsFile struct {
+	Jobs []*Job `toml:"job"`
+}
+
+// A named sync pair with the options of a sync upload or download.
+// The local path and drive directory are given directly or as the
+// name of a sync pair in the profile. The schedule is the minimum
+// duration between successful runs, i.e. 30m or 24h
+type Job struct {
+	Name             string   `toml:"name"`
+	Direction        string   `toml:"direction"`
+	Pair             string   `toml:"pair"`
+	Path             string   `toml:"path"`
+	FileId           string   `toml:"file_id"`
+	Resolution       string   `toml:"resolution"`
+	DeleteExtraneous bool     `toml:"delete_extraneous"`
+	Ignore           []string `toml:"ignore"`
+	Schedule         string   `toml:"schedule"`
+	Hash             string   `toml:"hash"`
+
+	resolution drive.ConflictResolution
+	interval   time.Duration
+	hash       drive.HashAlgorithm
+}
+
+func readJobs(path string) (*JobsFile, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("Jobs file %s not found", path)
+	}
+
+	jobs := &JobsFile{}
+	if _, err := toml.DecodeFile(path, jobs); err != nil {
+		return nil, fmt.Errorf("Failed to read jobs file %s: %s", path, err)
+	}
+
+	return jobs, nil
+}
+
+// Validates all jobs and resolves sync pair references against the profile
+func (self *JobsFile) prepare(profile *Profile) error {
+	seen := map[string]bool{}
+
+	for i, job := range self.Jobs {
+		if job.Name == "" {
+			return fmt.Errorf("Job %d is missing a name", i+1)
+		}
+
+		// The name is used for the lock and state files
+		if strings.ContainsAny(job.Name, `/\`) || job.Name == "." || job.Name == ".." {
+			return fmt.Errorf("Job name '%s' can not be used as a file name", job.Name)
+		}
+
+		if seen[job.Name] {
+			return fmt.Errorf("Job name '%s' is used more than once", job.Name)
+		}
+		seen[job.Name] = true
+
+		if err := job.prepare(profile); err != nil {
+			return fmt.Errorf("Invalid job '%s': %s", job.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns the named jobs in the given order, or all jobs in file order
+func (self *JobsFile) selectJobs(names []string) ([]*Job, error) {
+	if len(names) == 0 {
+		return self.Jobs, nil
+	}
+
+	byName := map[string]*Job{}
+	for _, job := range self.Jobs {
+		byName[job.Name] = job
+	}
+
+	var jobs []*Job
+	for _, name := range names {
+		job, found := byName[name]
+		if !found {
+			return nil, fmt.Errorf("Job '%s' not found", name)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func (self *Job) prepare(profile *Profile) error {
+	switch self.Direction {
+	case JobUpload, JobDownload:
+	default:
+		return fmt.Errorf("direction must be %s or %s", JobUpload, JobDownload)
+	}
+
+	if self.Pair != "" {
+		pair, found := profile.Sync[self.Pair]
+		if !found {
+			return fmt.Errorf("sync pair '%s' not found in profile", self.Pair)
+		}
+		if self.Path == "" {
+			self.Path = pair.Path
+		}
+		if self.FileId == "" {
+			self.FileId = pair.FileId
+		}
+	}
+
+	if self.Path == "" || self.FileId == "" {
+		return fmt.Errorf("path and file_id or a pair is required")
+	}
+
+	switch self.Resolution {
+	case "":
+		self.resolution = drive.NoResolution
+	case "keep-local":
+		self.resolution = drive.KeepLocal
+	case "keep-remote":
+		self.resolution = drive.KeepRemote
+	case "keep-largest":
+		self.resolution = drive.KeepLargest
+	default:
+		return fmt.Errorf("resolution must be keep-local, keep-remote or keep-largest")
+	}
+
+	if self.Schedule != "" {
+		interval, err := time.ParseDuration(self.Schedule)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("schedule must be a positive duration, i.e. 30m or 24h")
+		}
+		self.interval = interval
+	}
+
+	if self.Hash == "" {
+		self.Hash = DefaultHashAlgorithm
+	}
+
+	algo, err := drive.ParseHashAlgorithm(self.Hash)
+	if err != nil {
+		return err
+	}
+	self.hash = algo
+
+	return nil
+}
+
+type runJobsArgs struct {
+	out         io.Writer
+	progress    io.Writer
+	drive       *drive.Drive
+	cache       *cache.Cache
+	jobs        []*Job
+	stateDir    string
+	parallelism int
+	force       bool
+	forceUnlock bool
+	dryRun      bool
+	chunkSize   int64
+	timeout     time.Duration
+}
+
+// Runs the jobs in order, or in parallel with up to parallelism jobs at
+// the same time. Jobs that are not due or are already running are skipped
+func runJobs(args runJobsArgs) error {
+	if err := os.MkdirAll(args.stateDir, 0700); err != nil {
+		return fmt.Errorf("Failed to create jobs state dir: %s", err)
+	}
+
+	if args.parallelism < 1 {
+		args.parallelism = 1
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var failed int
+	sem := make(chan struct{}, args.parallelism)
+
+	for _, job := range args.jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(job *Job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			out := args.out
+			progress := args.progress
+			if args.parallelism > 1 {
+				w := &prefixWriter{mutex: &mutex, out: args.out, prefix: fmt.Sprintf("[%s] ", job.Name)}
+				defer w.Flush()
+				out = w
+				progress = ioutil.Discard
+			}
+
+			if err := runJob(args, job, out, progress); err != nil {
+				fmt.Fprintf(out, "Job %s failed: %s\n", job.Name, err)
+
+				mutex.Lock()
+				failed++
+				mutex.Unlock()
+			}
+		}(job)
+	}
+
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs failed", failed, len(args.jobs))
+	}
+	return nil
+}
+
+func runJob(args runJobsArgs, job *Job, out, progress io.Writer) error {
+	lastRun := readLastRun(args.stateDir, job.Name)
+	if !args.force && job.interval > 0 && time.Since(lastRun) < job.interval {
+		fmt.Fprintf(out, "Skipping job %s, next run is due at %s\n", job.Name, lastRun.Add(job.interval).Format(time.RFC3339))
+		return nil
+	}
+
+	lock, err := drive.LockFile(filepath.Join(args.stateDir, job.Name+".lock"), args.forceUnlock)
+	if err != nil {
+		fmt.Fprintf(out, "Skipping job %s: %s\n", job.Name, err)
+		return nil
+	}
+	defer lock.Release()
+
+	fmt.Fprintf(out, "Running job %s: sync %s %s %s\n", job.Name, job.Direction, job.Path, job.FileId)
+	started := time.Now()
+
+	comparer := NewCachedChecksumComparer(args.cache, job.hash)
+
+	if job.Direction == JobUpload {
+		err = args.drive.UploadSync(drive.UploadSyncArgs{
+			Out:              out,
+			Progress:         progress,
+			Path:             job.Path,
+			RootId:           job.FileId,
+			DryRun:           args.dryRun,
+			DeleteExtraneous: job.DeleteExtraneous,
+			ChunkSize:        args.chunkSize,
+			Timeout:          args.timeout,
+			Resolution:       job.resolution,
+			Comparer:         comparer,
+			Ignore:           job.Ignore,
+			ForceUnlock:      args.forceUnlock,
+		})
+	} else {
+		err = args.drive.DownloadSync(drive.DownloadSyncArgs{
+			Out:              out,
+			Progress:         progress,
+			Path:             job.Path,
+			RootId:           job.FileId,
+			DryRun:           args.dryRun,
+			DeleteExtraneous: job.DeleteExtraneous,
+			Timeout:          args.timeout,
+			Resolution:       job.resolution,
+			Comparer:         comparer,
+			Ignore:           job.Ignore,
+			ForceUnlock:      args.forceUnlock,
+		})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !args.dryRun {
+		if err := writeLastRun(args.stateDir, job.Name, started); err != nil {
+			return fmt.Errorf("Failed to save job state: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Returns the start time of the last successful run, or the zero time
+func readLastRun(stateDir, name string) time.Time {
+	content, err := ioutil.ReadFile(filepath.Join(stateDir, name+".last"))
+	if err != nil {
+		return time.Time{}
+	}
+
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(seconds, 0)
+}
+
+func writeLastRun(stateDir, name string, t time.Time) error {
+	path := filepath.Join(stateDir, name+".last")
+	return ioutil.WriteFile(path, []byte(fmt.Sprintf("%d\n", t.Unix())), 0600)
+}
+
+// Prefixes each line with the job name so the
+// output of parallel jobs can be told apart
+type prefixWriter struct {
+	mutex  *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (self *prefixWriter) Write(p []byte) (int, error) {
+	self.buf = append(self.buf, p...)
+
+	for {
+		i := bytes.IndexByte(self.buf, '\n')
+		if i < 0 {
+			break
+		}
+		self.writeLine(self.buf[:i+1])
+		self.buf = self.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (self *prefixWriter) Flush() {
+	if len(self.buf) > 0 {
+		self.writeLine(append(self.buf, '\n'))
+		self.buf = nil
+	}
+}
+
+func (self *prefixWriter) writeLine(line []byte) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	fmt.Fprintf(self.out, "%s%s", self.prefix, line)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/nanometrics/godrive/auth"
+	"github.com/nanometrics/godrive/cli"
+	"github.com/nanometrics/godrive/drive"
+	"golang.org/x/oauth2"
+)
+
+// Runs fn with the drive of the authenticated user, or for each
+// user given by --subjects-file or --all-users-from
+func runAsSubjects(args cli.Arguments, fn func(d *drive.Drive, out io.Writer) error) error {
+	subjects := subjectsFromArgs(args)
+	if subjects == nil {
+		return fn(newDrive(args), os.Stdout)
+	}
+
+	return runForSubjects(runForSubjectsArgs{
+		out:         os.Stdout,
+		subjects:    subjects,
+		parallelism: int(args.Int64("parallel")),
+		newDrive:    newSubjectDrive(args),
+		fn:          fn,
+	})
+}
+
+// Runs a command for each subject in parallel, the output of each subject
+// is written in one piece when it is done with every line tagged with the user
+type runForSubjectsArgs struct {
+	out         io.Writer
+	subjects    []string
+	parallelism int
+	newDrive    func(subject string) (*drive.Drive, error)
+	fn          func(d *drive.Drive, out io.Writer) error
+}
+
+func runForSubjects(args runForSubjectsArgs) error {
+	if args.parallelism < 1 {
+		args.parallelism = 1
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, args.parallelism)
+	failed := 0
+
+	for _, subject := range args.subjects {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(subject string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			buf := &bytes.Buffer{}
+			err := runForSubject(args, subject, buf)
+			if err != nil {
+				fmt.Fprintf(buf, "Failed: %s\n", err)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				failed++
+			}
+
+			w := &prefixWriter{mutex: &sync.Mutex{}, out: args.out, prefix: fmt.Sprintf("[%s] ", subject)}
+			w.Write(buf.Bytes())
+			w.Flush()
+		}(subject)
+	}
+
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d users failed", failed, len(args.subjects))
+	}
+	return nil
+}
+
+func runForSubject(args runForSubjectsArgs, subject string, out io.Writer) error {
+	d, err := args.newDrive(subject)
+	if err != nil {
+		return err
+	}
+	return args.fn(d, out)
+}
+
+// Returns the users given by --subjects-file or --all-users-from,
+// nil when the command should only run as a single user
+func subjectsFromArgs(args cli.Arguments) []string {
+	path := args.String("subjectsFile")
+	domain := args.String("allUsersFrom")
+
+	if path == "" && domain == "" {
+		return nil
+	}
+
+	if path != "" && domain != "" {
+		ExitF("--subjects-file can not be used with --all-users-from")
+	}
+
+	if !args.Bool("defaultCredentials") && args.String("serviceAccount") == "" {
+		ExitF("Running as other users requires --service-account or --default-credentials with domain-wide delegation")
+	}
+
+	var subjects []string
+	var err error
+
+	if path != "" {
+		subjects, err = readSubjectsFile(path)
+	} else {
+		subjects, err = domainUsers(args, domain)
+	}
+	checkErr(err)
+
+	if len(subjects) == 0 {
+		ExitF("No users to run the command for")
+	}
+	return subjects
+}
+
+// Reads one email per line, empty lines and lines starting with # are skipped
+func readSubjectsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open subjects file: %s", err)
+	}
+	defer f.Close()
+
+	var subjects []string
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+		seen[line] = true
+		subjects = append(subjects, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read subjects file: %s", err)
+	}
+	return subjects, nil
+}
+
+// Lists the users of the domain as the admin given by --subject
+func domainUsers(args cli.Arguments, domain string) ([]string, error) {
+	admin := args.String("subject")
+	if admin == "" {
+		return nil, fmt.Errorf("--all-users-from requires --subject with an admin of %s", domain)
+	}
+
+	tokenSource, err := impersonatedTokenSource(args, admin, auth.DirectoryUserScope)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.ListDomainUsers(oauth2.NewClient(oauth2.NoContext, tokenSource), domain)
+}
+
+// Returns a token source acting as subject, only service accounts
+// and application default credentials can impersonate users
+func impersonatedTokenSource(args cli.Arguments, subject, scope string) (oauth2.TokenSource, error) {
+	if args.Bool("defaultCredentials") {
+		credentials, err := auth.FindDefaultCredentials(scope, subject)
+		if err != nil {
+			return nil, err
+		}
+		return credentials.TokenSource, nil
+	}
+
+	if args.String("serviceAccount") != "" {
+		return auth.NewServiceAccountTokenSource(ConfigFilePath(getConfigDir(args), args.String("serviceAccount")), subject, scope)
+	}
+
+	return nil, fmt.Errorf("Impersonating users requires --service-account or --default-credentials")
+}
+
+func newSubjectDrive(args cli.Arguments) func(subject string) (*drive.Drive, error) {
+	return func(subject string) (*drive.Drive, error) {
+		tokenSource, err := impersonatedTokenSource(args, subject, oauthScope(args))
+		if err != nil {
+			return nil, err
+		}
+		return drive.New(oauth2.NewClient(oauth2.NoContext, tokenSource))
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func GetDefaultConfigDir() string {
@@ -45,6 +48,66 @@ func equal(a, b []string) bool {
 	return true
 }
 
+// Parses a duration like time.ParseDuration, but also
+// accepts days and weeks, i.e. 90d or 2w
+func parseAge(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	for suffix, unit := range units {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.ParseInt(strings.TrimSuffix(s, suffix), 10, 64)
+			if err != nil {
+				ExitF("Invalid age '%s'", s)
+			}
+			return time.Duration(n) * unit
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		ExitF("Invalid age '%s'", s)
+	}
+	return d
+}
+
+// Parses a point in time given as RFC 3339, with or
+// without seconds, or as a date in local time
+func parseTime(s string) time.Time {
+	layouts := []string{time.RFC3339, "2006-01-02T15:04Z07:00"}
+
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		ExitF("Invalid time '%s', expected i.e. 2006-01-02T15:04Z or 2006-01-02", s)
+	}
+	return t
+}
+
+// Parses an expiration given as a point in time, or as
+// a duration from now like parseAge, i.e. 30d or 12h
+func parseExpiration(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+
+	if strings.ContainsAny(s[len(s)-1:], "smhdw") {
+		return time.Now().Add(parseAge(s))
+	}
+	return parseTime(s)
+}
+
 func ExitF(format string, a ...interface{}) {
 	log.Fatalf(format, a...)
 }